@@ -8,9 +8,9 @@ export const contentType = "image/png";
 export default async function Image({
   params,
 }: {
-  params: Promise<{ slug: string }>;
+  params: Promise<{ slug: string[] }>;
 }) {
-  const { slug } = await params;
+  const slug = (await params).slug.join("/");
   const group = getBenchmarkGroup(slug);
   const meta = getBenchmarkMeta(slug);
   const implementationCount = group.Benchmarks.length;
@@ -39,17 +39,18 @@ import {
 import { SITE_URL, BASE_KEYWORDS } from "@/lib/seo";
 
 interface PageProps {
-  params: Promise<{ slug: string }>;
+  // Path of the group, split at category directories
+  params: Promise<{ slug: string[] }>;
 }
 
 export function generateStaticParams() {
-  return getAllSlugs().map((slug) => ({ slug }));
+  return getAllSlugs().map((slug) => ({ slug: slug.split("/") }));
 }
 
 export async function generateMetadata({
   params,
 }: PageProps): Promise<Metadata> {
-  const { slug } = await params;
+  const slug = (await params).slug.join("/");
   const group = getBenchmarkGroup(slug);
   const meta = getBenchmarkMeta(slug);
 
@@ -84,7 +85,7 @@ export async function generateMetadata({
 }
 
 export default async function BenchmarkPage({ params }: PageProps) {
-  const { slug } = await params;
+  const slug = (await params).slug.join("/");
 
   if (!getAllSlugs().includes(slug)) {
     notFound();
@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// runRecordFile is the file inside a group that run records how the
+// group's benchmark output was collected in.
+const runRecordFile = "_run.json"
+
+// Reasons for adaptive runs to stop.
+const (
+	stopStable   = "stable"
+	stopMaxCount = "max-count"
+	stopMaxTime  = "max-time"
+)
+
+// adaptiveOptions configures adaptive repetition: runs are repeated until
+// the confidence interval of the median ns/op of every result is at most
+// targetCI wide relative to the median, or a budget is exhausted.
+type adaptiveOptions struct {
+	targetCI float64       // Target relative width of the 95% confidence interval
+	maxCount int           // Maximum number of runs
+	maxTime  time.Duration // Maximum wall time of all runs of a group (0 for no limit)
+}
+
+// stopReason returns why adaptive runs should stop after runs runs taking
+// elapsed, or "" to continue. samples holds the ns/op of every run by
+// result.
+func (o adaptiveOptions) stopReason(samples map[string][]float64, runs int, elapsed time.Duration) string {
+	if width, ok := widestMedianCI(samples); ok && width <= o.targetCI {
+		return stopStable
+	}
+	if runs >= o.maxCount {
+		return stopMaxCount
+	}
+	if o.maxTime > 0 && elapsed >= o.maxTime {
+		return stopMaxTime
+	}
+	return ""
+}
+
+// widestMedianCI returns the widest relative confidence interval of the
+// median of any result in samples. It returns false if the interval of some
+// result cannot be estimated yet.
+func widestMedianCI(samples map[string][]float64) (float64, bool) {
+	widest := 0.0
+	for _, vals := range samples {
+		width, ok := relativeMedianCI(vals)
+		if !ok {
+			return 0, false
+		}
+		widest = max(widest, width)
+	}
+	return widest, len(samples) > 0
+}
+
+// relativeMedianCI returns the width of the 95% confidence interval of the
+// median of vals relative to the median. It returns false for fewer than
+// six values, which are too few for such an interval.
+func relativeMedianCI(vals []float64) (float64, bool) {
+	lo, hi, ok := medianCI(vals)
+	if !ok {
+		return 0, false
+	}
+	m := median(vals)
+	if m == 0 {
+		return 0, hi == lo
+	}
+	return (hi - lo) / m, true
+}
+
+// medianCI returns a distribution-free 95% confidence interval of the median
+// of vals: the k-th smallest and k-th largest value, with k chosen from the
+// binomial distribution of the number of values below the median so that
+// the interval covers it with at least 95% probability. Benchmark results are
+// skewed by outliers, which makes intervals assuming normality unreliable.
+func medianCI(vals []float64) (lo, hi float64, ok bool) {
+	n := len(vals)
+	k := 0
+	// P(X <= i) for X ~ Binomial(n, 0.5); the interval [x(k), x(n-k+1)]
+	// misses the median with probability 2 * P(X <= k-1).
+	cdf := 0.0
+	for i := 0; i < n/2; i++ {
+		cdf += binomialHalfPMF(n, i)
+		if 2*cdf > 0.05 {
+			break
+		}
+		k = i + 1
+	}
+	if k == 0 {
+		return 0, 0, false
+	}
+
+	sorted := slices.Clone(vals)
+	sort.Float64s(sorted)
+	return sorted[k-1], sorted[n-k], true
+}
+
+// binomialHalfPMF returns P(X = i) for X ~ Binomial(n, 0.5).
+func binomialHalfPMF(n, i int) float64 {
+	a, _ := math.Lgamma(float64(n + 1))
+	b, _ := math.Lgamma(float64(i + 1))
+	c, _ := math.Lgamma(float64(n - i + 1))
+	return math.Exp(a - b - c - float64(n)*math.Ln2)
+}
+
+// writeRunRecord writes the run record of the group at path.
+func writeRunRecord(path string, record parser.RunRecord) error {
+	b, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run record: %w", err)
+	}
+	return os.WriteFile(filepath.Join(path, runRecordFile), append(b, '\n'), 0644)
+}
+
+// machineRunRecordFile returns the name of the file that the run record of
+// the machine with the given fingerprint is merged into.
+func machineRunRecordFile(fingerprint string) string {
+	return "_run." + fingerprint + ".json"
+}
+
+// loadRunRecord reads a run record file. It returns nil if the file does
+// not exist.
+func loadRunRecord(file string) (*parser.RunRecord, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run record: %w", err)
+	}
+
+	var record parser.RunRecord
+	if err := json.Unmarshal(b, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode run record: %w", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,53 @@
+package commands
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMedianCI(t *testing.T) {
+	if _, _, ok := medianCI([]float64{1, 2, 3, 4, 5}); ok {
+		t.Error("expected no interval for five values")
+	}
+
+	// Six values: the interval spans the extremes (96.9% coverage).
+	lo, hi, ok := medianCI([]float64{6, 1, 5, 2, 4, 3})
+	if !ok || lo != 1 || hi != 6 {
+		t.Errorf("expected [1, 6], got [%v, %v] (%v)", lo, hi, ok)
+	}
+
+	// Twenty values: the 6th smallest and 6th largest (95.9% coverage).
+	var vals []float64
+	for i := 20; i >= 1; i-- {
+		vals = append(vals, float64(i))
+	}
+	lo, hi, ok = medianCI(vals)
+	if !ok || lo != 6 || hi != 15 {
+		t.Errorf("expected [6, 15], got [%v, %v] (%v)", lo, hi, ok)
+	}
+}
+
+func TestStopReason(t *testing.T) {
+	opts := adaptiveOptions{targetCI: 0.02, maxCount: 10, maxTime: time.Minute}
+
+	stable := map[string][]float64{"a": {100, 100.5, 99.5, 100, 100.2, 99.8}}
+	if got := opts.stopReason(stable, 6, time.Second); got != stopStable {
+		t.Errorf("expected %q, got %q", stopStable, got)
+	}
+
+	noisy := map[string][]float64{"a": {100, 150, 80, 120, 90, 200}, "b": {1, 1, 1, 1, 1, 1}}
+	if got := opts.stopReason(noisy, 6, time.Second); got != "" {
+		t.Errorf("expected to continue, got %q", got)
+	}
+	if got := opts.stopReason(noisy, 10, time.Second); got != stopMaxCount {
+		t.Errorf("expected %q, got %q", stopMaxCount, got)
+	}
+	if got := opts.stopReason(noisy, 6, time.Hour); got != stopMaxTime {
+		t.Errorf("expected %q, got %q", stopMaxTime, got)
+	}
+
+	// Too few runs for an interval never count as stable.
+	if got := opts.stopReason(map[string][]float64{"a": {1, 1, 1}}, 3, time.Second); got != "" {
+		t.Errorf("expected to continue, got %q", got)
+	}
+}
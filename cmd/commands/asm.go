@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// groupAssembly builds the test binary of the group at path and
+// disassembles the functions declared by each of its benchmarks (including
+// closures and generic instantiations of them). The result is keyed by
+// benchmark name.
+func groupAssembly(path string, benchmarks []parser.Benchmark) (map[string][]parser.AssemblyFunction, error) {
+	config := runConfig{dir: path}
+
+	out, err := config.goCommand("list", "-f", "{{.ImportPath}}", ".").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get import path: %w", err)
+	}
+	importPath := strings.TrimSpace(string(out))
+
+	tmp, err := os.MkdirTemp("", "gobench-asm-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	binary := filepath.Join(tmp, "bench.test")
+	if out, err := config.goCommand("test", "-c", "-o", binary).CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("failed to build test binary: %w: %s", err, out)
+	}
+
+	// Map every symbol that can be disassembled to the benchmark and
+	// declaration it belongs to.
+	type owner struct{ benchmark, decl string }
+	owners := make(map[string]owner)
+	var patterns []string
+	for _, bench := range benchmarks {
+		for _, src := range bench.Sources {
+			symbol := importPath + "." + src.Name
+			if src.Package != "" {
+				symbol = src.Package + "." + src.Name
+			}
+			owners[symbol] = owner{benchmark: bench.Name, decl: src.Name}
+			patterns = append(patterns, regexp.QuoteMeta(symbol))
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	pattern := "^(" + strings.Join(patterns, "|") + ")" + symbolSuffix
+	out, err = config.goCommand("tool", "objdump", "-s", pattern, binary).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to disassemble test binary: %w", err)
+	}
+
+	suffix := regexp.MustCompile(symbolSuffix)
+	result := make(map[string][]parser.AssemblyFunction)
+	for _, fn := range parseObjdump(out) {
+		o, ok := owners[suffix.ReplaceAllString(fn.Symbol, "")]
+		if !ok {
+			continue
+		}
+		fn.Name = o.decl
+		result[o.benchmark] = append(result[o.benchmark], fn)
+	}
+
+	return result, nil
+}
+
+// symbolSuffix matches what the compiler appends to the symbol of a
+// declaration for its generic instantiations and closures, e.g. "[...]",
+// ".func1" or ".gowrap2".
+const symbolSuffix = `(\[.*\])?(\.(func|gowrap)\d+(\.\d+)*)*$`
+
+// parseObjdump parses the output of "go tool objdump", which lists each
+// function as a "TEXT <symbol>(SB) <file>" header followed by one
+// tab-separated "<file>:<line> <address> <encoding> <instruction>" line per
+// instruction.
+func parseObjdump(out []byte) []parser.AssemblyFunction {
+	var functions []parser.AssemblyFunction
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if header, ok := strings.CutPrefix(line, "TEXT "); ok {
+			symbol, _, _ := strings.Cut(header, "(SB)")
+			functions = append(functions, parser.AssemblyFunction{Symbol: symbol})
+			continue
+		}
+		if len(functions) == 0 {
+			continue
+		}
+
+		var fields []string
+		for _, f := range strings.Split(line, "\t") {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		if len(fields) < 4 {
+			continue
+		}
+
+		file, lineNo, _ := strings.Cut(fields[0], ":")
+		n, _ := strconv.Atoi(lineNo)
+		fn := &functions[len(functions)-1]
+		fn.Instructions = append(fn.Instructions, parser.Instruction{
+			File:    file,
+			Line:    n,
+			Address: fields[1],
+			Op:      strings.Join(fields[3:], " "),
+		})
+	}
+
+	return functions
+}
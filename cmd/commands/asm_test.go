@@ -0,0 +1,34 @@
+package commands
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestParseObjdump(t *testing.T) {
+	out := "TEXT benchmarks/direct.BenchmarkDirectMethodCall_run(SB) /src/direct_test.go\n" +
+		"  direct_test.go:11\t0x543360\t\t31c9\t\t\tXORL CX, CX\t\t\n" +
+		"  direct_test.go:11\t0x543362\t\teb03\t\t\tJMP 0x543367\t\t\n" +
+		"  direct_test.go:14\t0x543370\t\tc3\t\t\tRET\t\t\t\n" +
+		"\n" +
+		"TEXT benchmarks/direct.BenchmarkDirectMethodCall_run.func1(SB) /src/direct_test.go\n" +
+		"  direct_test.go:12\t0x543380\t\t90\t\t\tNOPL\t\t\t\n"
+
+	functions := parseObjdump([]byte(out))
+	if len(functions) != 2 {
+		t.Fatalf("expected 2 functions, got %d", len(functions))
+	}
+
+	fn := functions[0]
+	if fn.Symbol != "benchmarks/direct.BenchmarkDirectMethodCall_run" || len(fn.Instructions) != 3 {
+		t.Fatalf("unexpected function: %+v", fn)
+	}
+	if in := fn.Instructions[1]; in.File != "direct_test.go" || in.Line != 11 || in.Address != "0x543362" || in.Op != "JMP 0x543367" {
+		t.Errorf("unexpected instruction: %+v", in)
+	}
+
+	suffix := regexp.MustCompile(symbolSuffix)
+	if got := suffix.ReplaceAllString(functions[1].Symbol, ""); got != fn.Symbol {
+		t.Errorf("expected closure to map to %s, got %s", fn.Symbol, got)
+	}
+}
@@ -0,0 +1,664 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	goparser "go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+	"github.com/marvinjwendt/gobench/cmd/internal/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/mod/modfile"
+)
+
+// bundleFormat is the version of the bundle layout, increased on
+// incompatible changes.
+const bundleFormat = 1
+
+// Files of a bundle besides the results of its groups, which are stored
+// below "groups/<group path>/".
+const (
+	bundleManifestFile  = "manifest.json"
+	bundleSignatureFile = "manifest.sig"
+)
+
+// bundleMaxFileSize limits the size of a single file read from a bundle.
+const bundleMaxFileSize = 256 << 20
+
+// bundleGroupFiles are the results of a group that are bundled, if present.
+var bundleGroupFiles = []string{"_bench.out", runRecordFile}
+
+// bundleManifest describes the contents of a bundle. It is signed with the
+// contributor's key, and the hashes of every other file in the bundle are
+// part of it, so the signature covers the whole bundle.
+type bundleManifest struct {
+	Format      int
+	Tool        string // Version of the tool the bundle was created with, see toolVersion
+	Created     string // RFC 3339
+	PublicKey   string // ed25519 public key of the signer, base64 encoded
+	Environment bundleEnvironment
+	Groups      []bundleGroup
+}
+
+// bundleEnvironment fingerprints the machine a bundle was recorded on.
+type bundleEnvironment struct {
+	Machine     string // Fingerprint of the machine, see parser.MachineFingerprint
+	GoOS        string
+	GoArch      string
+	CPU         string
+	NumCPU      int
+	GoVersion   string              // Go version the tool was built with
+	Checks      []bundleCheck       `json:",omitempty"` // Results of the doctor checks, on Linux
+	Calibration *parser.Calibration `json:",omitempty"` // Calibration of the machine, if calibrated
+}
+
+// bundleCheck is the result of a doctor check at the time a bundle was
+// created.
+type bundleCheck struct {
+	Name   string
+	Status string
+	Detail string
+}
+
+// bundleGroup lists the results of a group in a bundle.
+type bundleGroup struct {
+	Path    string            // Slash-separated path relative to the benchmarks directory
+	Sources map[string]string // SHA-256 of every source file the results were recorded with, by path relative to the group, see sourceHashes
+	Files   map[string]string // SHA-256 of every bundled result file, by file name
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import <bundle>...",
+	Short: "Verify signed result bundles created with \"run --bundle\" and merge them into the local results",
+	Long: `Import verifies the signature of each bundle and the hashes of every file in
+it, then merges the results of every group whose sources match the local
+sources exactly into the group, like the merge command. Groups whose sources
+differ, for example because the bundle was recorded on an older checkout, are
+skipped. With --trusted-keys, only bundles signed by one of the listed public
+keys are accepted.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debug, _ := cmd.Flags().GetBool("debug")
+		trustedKeysFile, _ := cmd.Flags().GetString("trusted-keys")
+		logger := logger.New(debug)
+
+		basePath := cmd.Flag("benchmarks").Value.String()
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return fmt.Errorf("benchmarks directory does not exist: %s", basePath)
+		}
+
+		var trusted []string
+		if trustedKeysFile != "" {
+			var err error
+			trusted, err = loadTrustedKeys(trustedKeysFile)
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, file := range args {
+			if err := importBundle(logger, basePath, file, trusted); err != nil {
+				return fmt.Errorf("failed to import %s: %w", file, err)
+			}
+		}
+
+		return nil
+	},
+}
+
+// writeBundle bundles the results of every group in the benchmarks
+// directory and signs them with the key in keyFile, which is generated if
+// it does not exist. Groups whose run record has no source hashes, because
+// they were run with an older version of the tool, are skipped.
+func writeBundle(logger *slog.Logger, basePath, file, keyFile string, env bundleEnvironment) error {
+	key, err := loadSigningKey(logger, keyFile)
+	if err != nil {
+		return err
+	}
+
+	manifest := bundleManifest{
+		Format:      bundleFormat,
+		Tool:        toolVersion(),
+		Created:     time.Now().UTC().Format(time.RFC3339),
+		PublicKey:   base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)),
+		Environment: env,
+	}
+
+	contents := make(map[string][]byte)
+	err = utils.WalkOverBenchmarks(basePath, func(dir string) error {
+		if _, err := os.Stat(filepath.Join(dir, "_bench.out")); err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(basePath, dir)
+		if err != nil {
+			return fmt.Errorf("failed to get relative group path: %w", err)
+		}
+		group := bundleGroup{Path: filepath.ToSlash(rel), Files: make(map[string]string)}
+
+		// The sources are those the results were recorded with, not the
+		// current ones, which may have changed since.
+		record, err := loadRunRecord(filepath.Join(dir, runRecordFile))
+		if err != nil {
+			return err
+		}
+		if record == nil || record.Sources == nil {
+			logger.Warn("skipping group without recorded source hashes, re-run it to bundle its results", "group", group.Path)
+			return nil
+		}
+		group.Sources = record.Sources
+
+		for _, name := range bundleGroupFiles {
+			b, err := os.ReadFile(filepath.Join(dir, name))
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			group.Files[name] = hashBytes(b)
+			contents[path.Join("groups", group.Path, name)] = b
+		}
+
+		if manifest.Environment.Machine == "" {
+			manifest.Environment.Machine, err = parser.ReadMachineFingerprint(filepath.Join(dir, "_bench.out"))
+			if err != nil {
+				return err
+			}
+		}
+
+		manifest.Groups = append(manifest.Groups, group)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(manifest.Groups) == 0 {
+		return fmt.Errorf("no results to bundle in %s", basePath)
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+	contents[bundleManifestFile] = b
+	contents[bundleSignatureFile] = []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(key, b)) + "\n")
+
+	f, err := os.Create(file)
+	if err != nil {
+		return fmt.Errorf("failed to create bundle: %w", err)
+	}
+	defer f.Close()
+
+	if err := writeTarGz(f, contents); err != nil {
+		return fmt.Errorf("failed to write bundle: %w", err)
+	}
+
+	logger.Info("wrote bundle", "file", file, "groups", len(manifest.Groups), "machine", manifest.Environment.Machine, "key", manifest.PublicKey)
+	return f.Close()
+}
+
+// bundleEnvironmentOf fingerprints the machine the tool runs on.
+func bundleEnvironmentOf(h host, calibration *parser.Calibration) bundleEnvironment {
+	env := bundleEnvironment{
+		GoOS:        runtime.GOOS,
+		GoArch:      runtime.GOARCH,
+		CPU:         h.cpuModel(),
+		NumCPU:      runtime.NumCPU(),
+		GoVersion:   runtime.Version(),
+		Calibration: calibration,
+	}
+	if runtime.GOOS == "linux" {
+		for _, check := range h.checks(runtime.NumCPU()) {
+			env.Checks = append(env.Checks, bundleCheck{Name: check.name, Status: check.status, Detail: check.detail})
+		}
+	}
+	return env
+}
+
+// importBundle verifies the bundle in file and merges the results of every
+// group whose sources match the local ones into the benchmarks directory.
+// If trusted is not empty, the bundle has to be signed by one of its keys.
+func importBundle(logger *slog.Logger, basePath, file string, trusted []string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	contents, err := readTarGz(f)
+	if err != nil {
+		return fmt.Errorf("failed to read bundle: %w", err)
+	}
+
+	manifest, err := verifyBundle(contents)
+	if err != nil {
+		return err
+	}
+	if len(trusted) > 0 && !slices.Contains(trusted, manifest.PublicKey) {
+		return fmt.Errorf("bundle is signed by untrusted key %s", manifest.PublicKey)
+	}
+	if len(trusted) == 0 {
+		logger.Warn("no trusted keys given, accepting any signer", "key", manifest.PublicKey)
+	}
+	if manifest.Tool != toolVersion() {
+		logger.Warn("bundle was created with a different version of the tool", "bundle", manifest.Tool, "local", toolVersion())
+	}
+
+	tmp, err := os.MkdirTemp("", "gobench-import-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	imported := 0
+	for _, group := range manifest.Groups {
+		dest := filepath.Join(basePath, filepath.FromSlash(group.Path))
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			logger.Warn("skipping group missing in the benchmarks directory", "group", group.Path)
+			continue
+		}
+
+		local, err := sourceHashes(dest)
+		if err != nil {
+			return err
+		}
+		if changed := changedSources(group.Sources, local); len(changed) > 0 {
+			logger.Warn("skipping group whose sources differ from the bundle", "group", group.Path, "files", changed)
+			continue
+		}
+
+		src := filepath.Join(tmp, filepath.FromSlash(group.Path))
+		if err := os.MkdirAll(src, 0755); err != nil {
+			return err
+		}
+		for name := range group.Files {
+			if err := os.WriteFile(filepath.Join(src, name), contents[path.Join("groups", group.Path, name)], 0644); err != nil {
+				return err
+			}
+		}
+
+		machines, err := mergeGroup(logger, src, dest)
+		if err != nil {
+			return fmt.Errorf("failed to merge %s: %w", group.Path, err)
+		}
+		for _, machine := range machines {
+			logger.Info("imported", "group", group.Path, "machine", machine)
+		}
+		imported += len(machines)
+	}
+
+	logger.Info("done", "bundle", file, "imported", imported, "groups", len(manifest.Groups))
+	return nil
+}
+
+// verifyBundle checks the signature of the manifest of a bundle and that
+// the bundle holds exactly the files listed in it, with matching hashes.
+func verifyBundle(contents map[string][]byte) (bundleManifest, error) {
+	var manifest bundleManifest
+
+	b, ok := contents[bundleManifestFile]
+	if !ok {
+		return manifest, fmt.Errorf("bundle has no %s", bundleManifestFile)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents[bundleSignatureFile])))
+	if err != nil || len(sig) == 0 {
+		return manifest, fmt.Errorf("bundle has no valid %s", bundleSignatureFile)
+	}
+
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return manifest, fmt.Errorf("failed to decode bundle manifest: %w", err)
+	}
+	if manifest.Format != bundleFormat {
+		return manifest, fmt.Errorf("unsupported bundle format %d", manifest.Format)
+	}
+	key, err := base64.StdEncoding.DecodeString(manifest.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return manifest, fmt.Errorf("bundle manifest has an invalid public key")
+	}
+	if !ed25519.Verify(key, b, sig) {
+		return manifest, fmt.Errorf("bundle signature is invalid")
+	}
+
+	listed := map[string]bool{bundleManifestFile: true, bundleSignatureFile: true}
+	for _, group := range manifest.Groups {
+		if !filepath.IsLocal(filepath.FromSlash(group.Path)) {
+			return manifest, fmt.Errorf("bundle contains invalid group path %q", group.Path)
+		}
+		for name, hash := range group.Files {
+			if !slices.Contains(bundleGroupFiles, name) {
+				return manifest, fmt.Errorf("bundle contains unexpected file %s in group %s", name, group.Path)
+			}
+			file := path.Join("groups", group.Path, name)
+			content, ok := contents[file]
+			if !ok {
+				return manifest, fmt.Errorf("bundle is missing %s", file)
+			}
+			if hashBytes(content) != hash {
+				return manifest, fmt.Errorf("hash of %s does not match the manifest", file)
+			}
+			listed[file] = true
+		}
+	}
+	for file := range contents {
+		if !listed[file] {
+			return manifest, fmt.Errorf("bundle contains unlisted file %s", file)
+		}
+	}
+
+	return manifest, nil
+}
+
+// sourceHashes returns the SHA-256 of every file the results of the group at
+// dir depend on, by slash-separated path relative to dir: the group's Go
+// files, go.mod, go.sum and _run.yml, the go.mod and go.sum of the module the
+// group belongs to, and the Go files of every shared helper package in
+// benchmarks/internal the group imports, directly or through other helpers.
+func sourceHashes(dir string) (map[string]string, error) {
+	hashes := make(map[string]string)
+	add := func(name string) error {
+		b, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, name)
+		if err != nil {
+			return err
+		}
+		hashes[filepath.ToSlash(rel)] = hashBytes(b)
+		return nil
+	}
+
+	groupFiles, err := sourceFiles(dir, func(name string) bool {
+		return strings.HasSuffix(name, ".go") || name == "go.mod" || name == "go.sum" || name == "_run.yml"
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range groupFiles {
+		if err := add(name); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := moduleRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	modFile := filepath.Join(root, "go.mod")
+	data, err := os.ReadFile(modFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range []string{modFile, filepath.Join(root, "go.sum")} {
+		if err := add(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	// Follow the imports of the group's Go files into the helper packages
+	helperPrefix := modfile.ModulePath(data) + "/" + utils.InternalDir + "/"
+	pending := slices.Clone(groupFiles)
+	seen := make(map[string]bool)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if !strings.HasSuffix(name, ".go") {
+			continue
+		}
+
+		f, err := goparser.ParseFile(token.NewFileSet(), name, nil, goparser.ImportsOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse imports of %s: %w", name, err)
+		}
+		for _, spec := range f.Imports {
+			importPath, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || !strings.HasPrefix(importPath, helperPrefix) || seen[importPath] {
+				continue
+			}
+			seen[importPath] = true
+
+			rel := strings.TrimPrefix(importPath, helperPrefix)
+			helperFiles, err := sourceFiles(filepath.Join(root, utils.InternalDir, filepath.FromSlash(rel)), func(name string) bool {
+				return strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go")
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read helper package %s: %w", importPath, err)
+			}
+			for _, helper := range helperFiles {
+				if err := add(helper); err != nil {
+					return nil, err
+				}
+			}
+			pending = append(pending, helperFiles...)
+		}
+	}
+
+	return hashes, nil
+}
+
+// sourceFiles returns the paths of the regular files in dir whose names
+// match, sorted.
+func sourceFiles(dir string, match func(name string) bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && match(entry.Name()) {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// changedSources returns the names of the files that were added, removed or
+// changed between two sets of source hashes, sorted.
+func changedSources(a, b map[string]string) []string {
+	var changed []string
+	for name, hash := range a {
+		if b[name] != hash {
+			changed = append(changed, name)
+		}
+	}
+	for name := range b {
+		if _, ok := a[name]; !ok {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// hashBytes returns the hex-encoded SHA-256 of b.
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// toolVersion returns the module version and VCS revision the tool was
+// built from, as far as recorded in its build info.
+func toolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	version := info.Main.Version
+	var revision, modified string
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value
+		}
+	}
+	if revision != "" {
+		version += " " + revision
+		if modified == "true" {
+			version += "-dirty"
+		}
+	}
+	return version
+}
+
+// defaultKeyFile returns the file the signing key of bundles is stored in
+// by default, in the user's configuration directory.
+func defaultKeyFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "gobench.key"
+	}
+	return filepath.Join(dir, "gobench", "bundle.key")
+}
+
+// loadSigningKey reads the ed25519 key in file, stored as the base64
+// encoded seed. A new key is generated and stored if the file does not
+// exist.
+func loadSigningKey(logger *slog.Logger, file string) (ed25519.PrivateKey, error) {
+	b, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate signing key: %w", err)
+		}
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+			return nil, fmt.Errorf("failed to store signing key: %w", err)
+		}
+		if err := os.WriteFile(file, []byte(base64.StdEncoding.EncodeToString(key.Seed())+"\n"), 0600); err != nil {
+			return nil, fmt.Errorf("failed to store signing key: %w", err)
+		}
+		logger.Info("generated signing key", "file", file, "key", base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)))
+		return key, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(b)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid signing key in %s", file)
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// loadTrustedKeys reads base64 encoded ed25519 public keys, one per line.
+// Empty lines and lines starting with "#" are ignored.
+func loadTrustedKeys(file string) ([]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(line)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid trusted key %q", line)
+		}
+		keys = append(keys, line)
+	}
+	return keys, nil
+}
+
+// writeTarGz writes contents as a gzip-compressed tar archive, sorted by
+// name.
+func writeTarGz(w io.Writer, contents map[string][]byte) error {
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range names {
+		header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(contents[name])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(contents[name]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// readTarGz reads the regular files of a gzip-compressed tar archive by
+// name.
+func readTarGz(r io.Reader) (map[string][]byte, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	contents := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return contents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("unexpected entry %s", header.Name)
+		}
+		if header.Size > bundleMaxFileSize {
+			return nil, fmt.Errorf("%s is too large", header.Name)
+		}
+
+		if _, ok := contents[header.Name]; ok {
+			return nil, fmt.Errorf("duplicate entry %s", header.Name)
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(tr, bundleMaxFileSize)); err != nil {
+			return nil, err
+		}
+		contents[header.Name] = buf.Bytes()
+	}
+}
+
+func init() {
+	importCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
+	importCmd.Flags().String("trusted-keys", "", "File of base64 encoded public keys, one per line, to accept bundles from (any signer is accepted if unset)")
+
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,180 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// writeGroup creates a benchmark group with results recorded on a machine
+// with the given CPU.
+func writeGroup(t *testing.T, dir, source, cpu string) {
+	t.Helper()
+	files := map[string]string{
+		"_meta.yml":     "name: Group\n",
+		"group_test.go": source,
+	}
+	if cpu != "" {
+		files["_bench.out"] = "goos: linux\ngoarch: amd64\ncpu: " + cpu + "\nBenchmarkGroup/Run-8   1000   10 ns/op\n"
+	}
+	writeFiles(t, dir, files)
+}
+
+// writeFiles creates files with the given contents, by path relative to dir.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// recordRun writes the run record of the group at dir, as run does.
+func recordRun(t *testing.T, dir string) {
+	t.Helper()
+	sources, err := sourceHashes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeRunRecord(dir, parser.RunRecord{Runs: 1, Sources: sources}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBundle(t *testing.T) {
+	root := t.TempDir()
+	remote, local := filepath.Join(root, "remote"), filepath.Join(root, "local")
+	writeFiles(t, remote, map[string]string{"go.mod": "module benchmarks\n"})
+	writeFiles(t, local, map[string]string{"go.mod": "module benchmarks\n"})
+	for _, name := range []string{"same", "changed", "stale", "unrecorded"} {
+		writeGroup(t, filepath.Join(remote, name), "package "+name+"\n", "Remote CPU")
+		if name != "unrecorded" {
+			recordRun(t, filepath.Join(remote, name))
+		}
+		writeGroup(t, filepath.Join(local, name), "package "+name+"\n", "")
+	}
+	writeFiles(t, local, map[string]string{"changed/group_test.go": "package changed // edited\n"})
+	// The stale group was edited after its results were recorded.
+	writeFiles(t, remote, map[string]string{"stale/group_test.go": "package stale // edited\n"})
+	writeFiles(t, local, map[string]string{"stale/group_test.go": "package stale // edited\n"})
+
+	bundle, keyFile := filepath.Join(root, "results.tar.gz"), filepath.Join(root, "keys", "bundle.key")
+	if err := writeBundle(logger.New(false), remote, bundle, keyFile, bundleEnvironment{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(keyFile); err != nil {
+		t.Fatalf("expected a signing key to be generated: %v", err)
+	}
+
+	if err := importBundle(logger.New(false), local, bundle, nil); err != nil {
+		t.Fatal(err)
+	}
+	machine := parser.MachineFingerprint(parser.SystemInfo{GoOS: "linux", GoArch: "amd64", CPU: "Remote CPU"})
+	if _, err := os.Stat(filepath.Join(local, "same", parser.MachineBenchFile(machine))); err != nil {
+		t.Errorf("expected the results of the unchanged group to be imported: %v", err)
+	}
+	for _, name := range []string{"changed", "stale", "unrecorded"} {
+		if _, err := os.Stat(filepath.Join(local, name, parser.MachineBenchFile(machine))); err == nil {
+			t.Errorf("expected the results of the %s group to be skipped", name)
+		}
+	}
+
+	if err := importBundle(logger.New(false), local, bundle, []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}); err == nil {
+		t.Error("expected a bundle of an untrusted key to be rejected")
+	}
+}
+
+func TestVerifyBundle(t *testing.T) {
+	root := t.TempDir()
+	writeGroup(t, filepath.Join(root, "benchmarks", "group"), "package group\n", "CPU")
+	writeFiles(t, filepath.Join(root, "benchmarks"), map[string]string{"go.mod": "module benchmarks\n"})
+	recordRun(t, filepath.Join(root, "benchmarks", "group"))
+	bundle := filepath.Join(root, "results.tar.gz")
+	if err := writeBundle(logger.New(false), filepath.Join(root, "benchmarks"), bundle, filepath.Join(root, "bundle.key"), bundleEnvironment{}); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func() map[string][]byte {
+		b, err := os.ReadFile(bundle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := readTarGz(bytes.NewReader(b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return contents
+	}
+
+	if _, err := verifyBundle(read()); err != nil {
+		t.Fatalf("expected the bundle to verify: %v", err)
+	}
+
+	tampered := read()
+	tampered["groups/group/_bench.out"] = bytes.Replace(tampered["groups/group/_bench.out"], []byte("10 ns/op"), []byte("1 ns/op"), 1)
+	if _, err := verifyBundle(tampered); err == nil {
+		t.Error("expected edited results to be rejected")
+	}
+
+	tampered = read()
+	tampered[bundleManifestFile] = bytes.Replace(tampered[bundleManifestFile], []byte(`"Format": 1`), []byte(`"Format":  1`), 1)
+	if _, err := verifyBundle(tampered); err == nil {
+		t.Error("expected an edited manifest to be rejected")
+	}
+
+	tampered = read()
+	tampered["groups/group/extra.out"] = []byte("unlisted")
+	if _, err := verifyBundle(tampered); err == nil {
+		t.Error("expected unlisted files to be rejected")
+	}
+}
+
+func TestSourceHashes(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"go.mod":                         "module benchmarks\n",
+		"go.sum":                         "",
+		"internal/random/random.go":      "package random\n\nimport _ \"benchmarks/internal/seed\"\n",
+		"internal/random/random_test.go": "package random\n",
+		"internal/seed/seed.go":          "package seed\n",
+		"internal/unused/unused.go":      "package unused\n",
+		"group/group_test.go":            "package group\n\nimport (\n\t\"testing\"\n\n\t\"benchmarks/internal/random\"\n)\n",
+		"group/_meta.yml":                "name: Group\n",
+		"group/_bench.out":               "",
+	})
+
+	hashes, err := sourceHashes(filepath.Join(root, "group"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := []string{"../go.mod", "../go.sum", "../internal/random/random.go", "../internal/seed/seed.go", "group_test.go"}
+	if !slices.Equal(names, want) {
+		t.Errorf("expected hashes of %v, got %v", want, names)
+	}
+
+	// Editing a helper the group imports indirectly changes its sources.
+	writeFiles(t, root, map[string]string{"internal/seed/seed.go": "package seed // edited\n"})
+	edited, err := sourceHashes(filepath.Join(root, "group"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed := changedSources(hashes, edited); !slices.Equal(changed, []string{"../internal/seed/seed.go"}) {
+		t.Errorf("expected the helper to be changed, got %v", changed)
+	}
+}
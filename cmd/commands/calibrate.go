@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+	"github.com/spf13/cobra"
+)
+
+// calibrationFile is the file in the benchmarks directory that calibrate
+// stores the machine score in. It is specific to the machine and ignored by
+// git.
+const calibrationFile = "_calibration.json"
+
+// calibrationRepeats is the number of times each calibration benchmark is
+// run; the median is kept.
+const calibrationRepeats = 3
+
+// calibrationBuffer is the size of the buffers copied by the memory
+// bandwidth benchmark, well beyond the caches of current CPUs.
+const calibrationBuffer = 64 << 20
+
+// calibrationSink keeps the compiler from eliminating calibration workloads.
+var calibrationSink any
+
+// calibrationBenchmark is a benchmark of the calibration suite.
+type calibrationBenchmark struct {
+	name string
+	fn   func(b *testing.B)
+}
+
+// calibrationSuite covers the resources benchmarks typically depend on:
+// integer arithmetic, memory bandwidth, the allocator and atomic operations
+// under contention.
+var calibrationSuite = []calibrationBenchmark{
+	{"alu", func(b *testing.B) {
+		var x uint64
+		for range b.N {
+			x += sentinelWork(64)
+		}
+		calibrationSink = x
+	}},
+	{"memory", func(b *testing.B) {
+		src := make([]byte, calibrationBuffer)
+		dst := make([]byte, calibrationBuffer)
+		b.SetBytes(calibrationBuffer)
+		b.ResetTimer()
+		for range b.N {
+			copy(dst, src)
+		}
+		calibrationSink = dst
+	}},
+	{"alloc", func(b *testing.B) {
+		for range b.N {
+			calibrationSink = make([]byte, 64)
+		}
+	}},
+	{"atomic", func(b *testing.B) {
+		var n atomic.Int64
+		b.SetParallelism(1)
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				n.Add(1)
+			}
+		})
+		calibrationSink = n.Load()
+	}},
+}
+
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate",
+	Short: "Measure this machine with a built-in benchmark suite to normalize results across machines",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debug, _ := cmd.Flags().GetBool("debug")
+		logger := logger.New(debug)
+
+		basePath := cmd.Flag("benchmarks").Value.String()
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return fmt.Errorf("benchmarks directory does not exist: %s", basePath)
+		}
+
+		calibration := calibrate(func(name string, nsPerOp float64) {
+			logger.Info("calibration benchmark", "name", name, "ns/op", nsPerOp)
+		})
+		logger.Info("machine score", "score", calibration.Score)
+
+		b, err := json.MarshalIndent(calibration, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to encode calibration: %w", err)
+		}
+		return os.WriteFile(filepath.Join(basePath, calibrationFile), append(b, '\n'), 0644)
+	},
+}
+
+// calibrate runs the calibration suite, reporting each result to progress.
+func calibrate(progress func(name string, nsPerOp float64)) parser.Calibration {
+	calibration := parser.Calibration{
+		GoOS:      runtime.GOOS,
+		GoArch:    runtime.GOARCH,
+		CPU:       host{root: "/"}.cpuModel(),
+		NumCPU:    runtime.NumCPU(),
+		GoVersion: runtime.Version(),
+		Date:      time.Now().UTC().Format(time.RFC3339),
+	}
+
+	var nsPerOp []float64
+	for _, bench := range calibrationSuite {
+		var runs []float64
+		for range calibrationRepeats {
+			r := testing.Benchmark(bench.fn)
+			runs = append(runs, float64(r.T.Nanoseconds())/float64(r.N))
+		}
+		result := parser.CalibrationResult{Name: bench.name, NsPerOp: median(runs)}
+		calibration.Results = append(calibration.Results, result)
+		nsPerOp = append(nsPerOp, result.NsPerOp)
+		progress(result.Name, result.NsPerOp)
+	}
+	calibration.Score = machineScore(nsPerOp)
+
+	return calibration
+}
+
+// machineScore returns the geometric mean of the ns/op of the calibration
+// suite. Dividing ns/op by it puts results of different machines on a common
+// scale; the geometric mean keeps every benchmark equally weighted although
+// their ns/op differ by orders of magnitude.
+func machineScore(nsPerOp []float64) float64 {
+	if len(nsPerOp) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range nsPerOp {
+		sum += math.Log(v)
+	}
+	return math.Exp(sum / float64(len(nsPerOp)))
+}
+
+// cpuModel returns the CPU model name from /proc/cpuinfo, or "" if not
+// available.
+func (h host) cpuModel() string {
+	cpuinfo, err := h.read("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
+
+// loadCalibration reads the calibration stored in the benchmarks directory.
+// It returns nil if the machine was not calibrated.
+func loadCalibration(basePath string) (*parser.Calibration, error) {
+	b, err := os.ReadFile(filepath.Join(basePath, calibrationFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read calibration: %w", err)
+	}
+
+	var calibration parser.Calibration
+	if err := json.Unmarshal(b, &calibration); err != nil {
+		return nil, fmt.Errorf("failed to decode calibration: %w", err)
+	}
+	return &calibration, nil
+}
+
+// normalizeVariations sets the normalized ns/op of every variation of the
+// group and of every merged machine from the score of the machine the
+// results were recorded on. Results of machines that were not calibrated are
+// left unchanged.
+func normalizeVariations(group *parser.BenchmarkGroup) {
+	for i := range group.Benchmarks {
+		normalize(group.Benchmarks[i].Variations, group.Run)
+	}
+	for _, machine := range group.Machines {
+		for _, bench := range machine.Benchmarks {
+			normalize(bench.Variations, machine.Run)
+		}
+	}
+}
+
+// normalize divides the ns/op of variations by the machine score in record.
+func normalize(variations []parser.Variation, record *parser.RunRecord) {
+	if record == nil || record.Calibration == nil || record.Calibration.Score == 0 {
+		return
+	}
+	for i := range variations {
+		variations[i].NormalizedNsPerOp = variations[i].NsPerOp / record.Calibration.Score
+	}
+}
+
+func init() {
+	calibrateCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
+
+	rootCmd.AddCommand(calibrateCmd)
+}
@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestMachineScore(t *testing.T) {
+	if score := machineScore([]float64{1, 1e6}); math.Abs(score-1e3) > 1e-9 {
+		t.Errorf("expected 1000, got %v", score)
+	}
+	// The geometric mean weights a 4x faster ALU and a 4x slower memory
+	// copy equally.
+	if machineScore([]float64{0.25, 4e6}) != machineScore([]float64{1, 1e6}) {
+		t.Error("expected offsetting changes to keep the score")
+	}
+}
+
+func TestNormalizeVariations(t *testing.T) {
+	variation := func(nsPerOp float64) parser.Variation {
+		var v parser.Variation
+		v.NsPerOp = nsPerOp
+		return v
+	}
+	group := parser.BenchmarkGroup{Benchmarks: []parser.Benchmark{{Variations: []parser.Variation{variation(50), variation(200)}}}}
+
+	normalizeVariations(&group)
+	if group.Benchmarks[0].Variations[0].NormalizedNsPerOp != 0 {
+		t.Error("expected no normalization without calibration")
+	}
+
+	group.Run = &parser.RunRecord{Calibration: &parser.Calibration{Score: 100}}
+	normalizeVariations(&group)
+	if v := group.Benchmarks[0].Variations; v[0].NormalizedNsPerOp != 0.5 || v[1].NormalizedNsPerOp != 2 {
+		t.Errorf("unexpected normalized ns/op %v, %v", v[0].NormalizedNsPerOp, v[1].NormalizedNsPerOp)
+	}
+}
+
+func TestCPUModel(t *testing.T) {
+	h := writeHostFiles(t, map[string]string{
+		"proc/cpuinfo": "processor\t: 0\nvendor_id\t: AuthenticAMD\nmodel name\t: AMD Ryzen 9 7950X 16-Core Processor\n",
+	})
+	if model := h.cpuModel(); model != "AMD Ryzen 9 7950X 16-Core Processor" {
+		t.Errorf("unexpected cpu model %q", model)
+	}
+}
@@ -0,0 +1,275 @@
+package commands
+
+import (
+	"cmp"
+	"maps"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// complexityClass is a candidate growth function of the cost per op over
+// the input size n.
+type complexityClass struct {
+	name string
+	f    func(n float64) float64
+}
+
+// complexityClasses are the candidates, from the simplest to the most
+// complex. A simpler class is preferred if it fits about as well.
+var complexityClasses = []complexityClass{
+	{"O(log n)", math.Log2},
+	{"O(n)", func(n float64) float64 { return n }},
+	{"O(n log n)", func(n float64) float64 { return n * math.Log2(n) }},
+	{"O(n²)", func(n float64) float64 { return n * n }},
+}
+
+const (
+	// constantGrowth is the relative growth of ns/op over all sizes below
+	// which a behavior is considered O(1).
+	constantGrowth = 0.1
+	// complexityTolerance and complexityMargin define how much larger the
+	// relative error of a simpler class may be than the best one for the
+	// simpler class to be chosen: best*tolerance + margin.
+	complexityTolerance = 1.25
+	complexityMargin    = 0.01
+)
+
+// sizeSeriesKey identifies the results of a behavior over the input sizes.
+type sizeSeriesKey struct {
+	Variation string
+	CPUCount  int
+	GoVersion string
+	Variant   string
+	Labels    string // Canonical form of the labels without "size"
+}
+
+// sizeSeries returns the median ns/op of every behavior by input size, taken
+// from the "size" label, along with the remaining labels of each series.
+func sizeSeries(bench parser.Benchmark) (map[sizeSeriesKey]map[int]float64, map[sizeSeriesKey]map[string]string, []sizeSeriesKey) {
+	var sized []parser.Variation
+	for _, v := range bench.Variations {
+		if _, err := strconv.Atoi(v.Labels["size"]); err == nil {
+			sized = append(sized, v)
+		}
+	}
+
+	grouped := groupSeries(sized,
+		func(v parser.Variation) sizeSeriesKey {
+			return sizeSeriesKey{Variation: v.Name, CPUCount: v.CPUCount, GoVersion: v.GoVersion, Variant: v.Variant, Labels: labelsKey(withoutSize(v.Labels))}
+		},
+		func(a, b sizeSeriesKey) int {
+			return cmp.Or(cmp.Compare(a.Variation, b.Variation), cmp.Compare(a.CPUCount, b.CPUCount))
+		})
+
+	series := make(map[sizeSeriesKey]map[int]float64, len(grouped))
+	labels := make(map[sizeSeriesKey]map[string]string, len(grouped))
+	order := make([]sizeSeriesKey, 0, len(grouped))
+	for _, vs := range grouped {
+		bySize := make(map[int][]parser.Variation)
+		for _, v := range vs.variations {
+			size, _ := strconv.Atoi(v.Labels["size"])
+			bySize[size] = append(bySize[size], v)
+		}
+
+		series[vs.key] = make(map[int]float64, len(bySize))
+		for size, vars := range bySize {
+			series[vs.key][size] = medianFloat(vars, func(v parser.Variation) float64 { return v.NsPerOp })
+		}
+		labels[vs.key] = withoutSize(vs.labels)
+		order = append(order, vs.key)
+	}
+
+	return series, labels, order
+}
+
+// withoutSize returns a copy of labels without the "size" label, or nil if
+// no other labels remain.
+func withoutSize(labels map[string]string) map[string]string {
+	other := maps.Clone(labels)
+	delete(other, "size")
+	if len(other) == 0 {
+		return nil
+	}
+	return other
+}
+
+// complexityFits determines the complexity class of every behavior of a
+// benchmark that was run with at least three input sizes.
+func complexityFits(bench parser.Benchmark) []parser.ComplexityFit {
+	series, labels, order := sizeSeries(bench)
+
+	var fits []parser.ComplexityFit
+	for _, key := range order {
+		if len(series[key]) < 3 {
+			continue
+		}
+
+		sizes := slices.Sorted(maps.Keys(series[key]))
+		ns := make([]float64, len(sizes))
+		for i, size := range sizes {
+			ns[i] = series[key][size]
+		}
+
+		fit := fitComplexity(sizes, ns)
+		fit.Variation = key.Variation
+		fit.CPUCount = key.CPUCount
+		fit.GoVersion = key.GoVersion
+		fit.Variant = key.Variant
+		fit.Labels = labels[key]
+		fits = append(fits, fit)
+	}
+
+	return fits
+}
+
+// fitComplexity fits ns = Constant + Coefficient * f(size) for every
+// complexity class and returns the simplest class that fits about as well as
+// the best one. The fits minimise the relative rather than the absolute
+// error, as sizes usually span orders of magnitude and the largest size
+// would otherwise dominate.
+func fitComplexity(sizes []int, ns []float64) parser.ComplexityFit {
+	mean := 0.0
+	for _, y := range ns {
+		mean += y
+	}
+	mean /= float64(len(ns))
+
+	constant := parser.ComplexityFit{Class: "O(1)", Constant: mean, RelativeError: relativeError(ns, func(int) float64 { return mean }), Sizes: len(sizes)}
+
+	// A cost that barely grows over all sizes is constant, whatever fits best.
+	if minNs, maxNs := slices.Min(ns), slices.Max(ns); mean == 0 || (maxNs-minNs)/mean < constantGrowth {
+		return constant
+	}
+
+	var fits []parser.ComplexityFit
+	best := math.Inf(1)
+	for _, class := range complexityClasses {
+		xs := make([]float64, len(sizes))
+		for i, size := range sizes {
+			xs[i] = class.f(float64(size))
+		}
+
+		coefficient, c := relativeLeastSquares(xs, ns)
+		if coefficient <= 0 {
+			continue
+		}
+		err := relativeError(ns, func(i int) float64 { return c + coefficient*xs[i] })
+		fits = append(fits, parser.ComplexityFit{Class: class.name, Coefficient: coefficient, Constant: c, RelativeError: err, Sizes: len(sizes)})
+		best = min(best, err)
+	}
+
+	for _, fit := range fits {
+		if fit.RelativeError <= best*complexityTolerance+complexityMargin {
+			return fit
+		}
+	}
+	return constant
+}
+
+// relativeLeastSquares fits y = slope*x + intercept by least squares of the
+// relative error, i.e. weighted by 1/y².
+func relativeLeastSquares(xs, ys []float64) (slope, intercept float64) {
+	var sw, sx, sy, sxx, sxy float64
+	for i := range xs {
+		if ys[i] == 0 {
+			continue
+		}
+		w := 1 / (ys[i] * ys[i])
+		sw += w
+		sx += w * xs[i]
+		sy += w * ys[i]
+		sxx += w * xs[i] * xs[i]
+		sxy += w * xs[i] * ys[i]
+	}
+
+	den := sw*sxx - sx*sx
+	if den == 0 {
+		return 0, sy / sw
+	}
+	slope = (sw*sxy - sx*sy) / den
+	return slope, (sy - slope*sx) / sw
+}
+
+// relativeError returns the root mean square of the relative deviations of
+// ys from the fitted values.
+func relativeError(ys []float64, fitted func(i int) float64) float64 {
+	sum := 0.0
+	for i, y := range ys {
+		if y != 0 {
+			d := (y - fitted(i)) / y
+			sum += d * d
+		}
+	}
+	return math.Sqrt(sum / float64(len(ys)))
+}
+
+// crossovers finds the input sizes at which one implementation of a group
+// overtakes another, by interpolating between the measured sizes on a log
+// scale.
+func crossovers(group parser.BenchmarkGroup) []parser.Crossover {
+	type benchSeries struct {
+		name   string
+		series map[sizeSeriesKey]map[int]float64
+		labels map[sizeSeriesKey]map[string]string
+		order  []sizeSeriesKey
+	}
+
+	all := make([]benchSeries, len(group.Benchmarks))
+	for i, bench := range group.Benchmarks {
+		series, labels, order := sizeSeries(bench)
+		all[i] = benchSeries{bench.Name, series, labels, order}
+	}
+
+	var result []parser.Crossover
+	for i, a := range all {
+		for _, b := range all[i+1:] {
+			for _, key := range a.order {
+				bySizeA, bySizeB := a.series[key], b.series[key]
+				if bySizeB == nil {
+					continue
+				}
+
+				var sizes []int
+				for size := range bySizeA {
+					if _, ok := bySizeB[size]; ok {
+						sizes = append(sizes, size)
+					}
+				}
+				sort.Ints(sizes)
+
+				for k := 1; k < len(sizes); k++ {
+					lo, hi := sizes[k-1], sizes[k]
+					dLo, dHi := bySizeA[lo]-bySizeB[lo], bySizeA[hi]-bySizeB[hi]
+					if dLo == 0 || dHi == 0 || (dLo < 0) == (dHi < 0) {
+						continue
+					}
+
+					t := dLo / (dLo - dHi)
+					size := math.Exp(math.Log(float64(lo)) + t*(math.Log(float64(hi))-math.Log(float64(lo))))
+
+					below, above := a.name, b.name
+					if dLo > 0 {
+						below, above = b.name, a.name
+					}
+
+					result = append(result, parser.Crossover{
+						Variation:   key.Variation,
+						CPUCount:    key.CPUCount,
+						GoVersion:   key.GoVersion,
+						Variant:     key.Variant,
+						Labels:      a.labels[key],
+						Size:        size,
+						FasterBelow: below,
+						FasterAbove: above,
+					})
+				}
+			}
+		}
+	}
+
+	return result
+}
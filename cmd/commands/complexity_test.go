@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestFitComplexity(t *testing.T) {
+	sizes := []int{10, 100, 1000, 10000}
+	tests := []struct {
+		class string
+		cost  func(n float64) float64
+	}{
+		{"O(1)", func(n float64) float64 { return 50 + math.Mod(n, 3) }},
+		{"O(log n)", func(n float64) float64 { return 10 + 4*math.Log2(n) }},
+		{"O(n)", func(n float64) float64 { return 20 + 2*n }},
+		{"O(n log n)", func(n float64) float64 { return 3 * n * math.Log2(n) }},
+		{"O(n²)", func(n float64) float64 { return 0.5 * n * n }},
+	}
+
+	for _, tt := range tests {
+		ns := make([]float64, len(sizes))
+		for i, size := range sizes {
+			ns[i] = tt.cost(float64(size))
+		}
+
+		fit := fitComplexity(sizes, ns)
+		if fit.Class != tt.class {
+			t.Errorf("expected %s, got %s (%+v)", tt.class, fit.Class, fit)
+		}
+	}
+}
+
+func TestCrossovers(t *testing.T) {
+	benchmark := func(name string, cost func(n float64) float64) parser.Benchmark {
+		bench := parser.Benchmark{Name: name}
+		for _, size := range []int{10, 100, 1000} {
+			v := parser.Variation{Name: "sort", CPUCount: 1, Labels: map[string]string{"size": strconv.Itoa(size)}}
+			v.NsPerOp = cost(float64(size))
+			bench.Variations = append(bench.Variations, v)
+		}
+		return bench
+	}
+
+	group := parser.BenchmarkGroup{Benchmarks: []parser.Benchmark{
+		benchmark("Insertion Sort", func(n float64) float64 { return n * n }),
+		benchmark("Quick Sort", func(n float64) float64 { return 400 + n*math.Log2(n) }),
+	}}
+
+	result := crossovers(group)
+	if len(result) != 1 {
+		t.Fatalf("expected 1 crossover, got %+v", result)
+	}
+
+	c := result[0]
+	if c.FasterBelow != "Insertion Sort" || c.FasterAbove != "Quick Sort" || c.Labels != nil {
+		t.Errorf("unexpected crossover: %+v", c)
+	}
+	if c.Size <= 10 || c.Size >= 100 {
+		t.Errorf("expected crossover between 10 and 100, got %f", c.Size)
+	}
+}
@@ -0,0 +1,195 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// rewriteConsts sets the values of package level constants declared in the
+// Go files of dir, e.g. {"mapSize": "100"}. Values are Go expressions, except
+// for constants declared with a string literal, whose values are quoted if
+// needed. It fails if a constant is not declared in dir.
+func rewriteConsts(dir string, values map[string]string) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read group directory: %w", err)
+	}
+
+	found := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		name := filepath.Join(dir, entry.Name())
+		src, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+
+		file, err := decorator.Parse(src)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+
+		changed := false
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*dst.GenDecl)
+			if !ok || gen.Tok != token.CONST {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				vs := spec.(*dst.ValueSpec)
+				for i, ident := range vs.Names {
+					value, ok := values[ident.Name]
+					if !ok || i >= len(vs.Values) {
+						continue
+					}
+					expr, err := constExpr(vs.Values[i], value)
+					if err != nil {
+						return fmt.Errorf("invalid value for constant %s: %w", ident.Name, err)
+					}
+					vs.Values[i] = expr
+					found[ident.Name] = true
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := decorator.Fprint(&buf, file); err != nil {
+			return fmt.Errorf("failed to print %s: %w", entry.Name(), err)
+		}
+		if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", entry.Name(), err)
+		}
+	}
+
+	var missing []string
+	for name := range values {
+		if !found[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("constants not found in %s: %s", dir, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// declaredConsts returns the names of the package level constants declared
+// in the Go files of dir.
+func declaredConsts(dir string) (map[string]bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read group directory: %w", err)
+	}
+
+	declared := make(map[string]bool)
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
+
+		file, err := goparser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, goparser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", entry.Name(), err)
+		}
+		for _, decl := range file.Decls {
+			if gen, ok := decl.(*ast.GenDecl); ok && gen.Tok == token.CONST {
+				for _, spec := range gen.Specs {
+					for _, ident := range spec.(*ast.ValueSpec).Names {
+						declared[ident.Name] = true
+					}
+				}
+			}
+		}
+	}
+
+	return declared, nil
+}
+
+// constExpr returns the expression for the new value of a constant
+// currently declared as old.
+func constExpr(old dst.Expr, value string) (dst.Expr, error) {
+	if lit, ok := old.(*dst.BasicLit); ok && lit.Kind == token.STRING {
+		if _, err := strconv.Unquote(value); err != nil {
+			value = strconv.Quote(value)
+		}
+	}
+
+	expr, err := goparser.ParseExpr(value)
+	if err != nil {
+		return nil, err
+	}
+	if lit, ok := expr.(*ast.BasicLit); ok {
+		return &dst.BasicLit{Kind: lit.Kind, Value: lit.Value}, nil
+	}
+	// Other expressions, e.g. "1 << 10", are printed verbatim.
+	return dst.NewIdent(value), nil
+}
+
+// parseSweep parses a sweep of the form "name=v1,v2,...".
+func parseSweep(value string) (string, []string, error) {
+	name, values, ok := strings.Cut(value, "=")
+	if !ok || name == "" || values == "" {
+		return "", nil, fmt.Errorf("invalid sweep %q, expected name=value[,value...]", value)
+	}
+	return name, slices.DeleteFunc(strings.Split(values, ","), func(v string) bool { return v == "" }), nil
+}
+
+// groupSweeps lists the constants a group was swept over, derived from the
+// "const/<name>" labels of its results. Numeric values are sorted by value,
+// others lexically.
+func groupSweeps(group parser.BenchmarkGroup) []parser.Sweep {
+	values := make(map[string][]string)
+	for _, bench := range group.Benchmarks {
+		for _, v := range bench.Variations {
+			for key, value := range v.Labels {
+				name, ok := strings.CutPrefix(key, "const/")
+				if ok && !slices.Contains(values[name], value) {
+					values[name] = append(values[name], value)
+				}
+			}
+		}
+	}
+
+	var sweeps []parser.Sweep
+	for name, vs := range values {
+		sort.SliceStable(vs, func(i, j int) bool {
+			a, errA := strconv.ParseFloat(vs[i], 64)
+			b, errB := strconv.ParseFloat(vs[j], 64)
+			if errA == nil && errB == nil {
+				return a < b
+			}
+			return vs[i] < vs[j]
+		})
+		sweeps = append(sweeps, parser.Sweep{Const: name, Values: vs})
+	}
+	sort.Slice(sweeps, func(i, j int) bool { return sweeps[i].Const < sweeps[j].Const })
+
+	return sweeps
+}
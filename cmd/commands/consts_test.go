@@ -0,0 +1,62 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestRewriteConsts(t *testing.T) {
+	dir := t.TempDir()
+	src := `package maps
+
+// mapSize is the key space used by all benchmarks in this package.
+const mapSize = 1000
+
+const (
+	prefix  = "key"
+	workers = 4
+)
+`
+	if err := os.WriteFile(filepath.Join(dir, "a-consts.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err := rewriteConsts(dir, map[string]string{"mapSize": "100", "prefix": "id", "workers": "1 << 3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "a-consts.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"// mapSize is the key space", "const mapSize = 100\n", `prefix  = "id"`, "workers = 1 << 3"} {
+		if !strings.Contains(string(got), want) {
+			t.Errorf("expected %q in:\n%s", want, got)
+		}
+	}
+
+	if err := rewriteConsts(dir, map[string]string{"missing": "1"}); err == nil {
+		t.Error("expected error for undeclared constant")
+	}
+}
+
+func TestGroupSweeps(t *testing.T) {
+	group := parser.BenchmarkGroup{Benchmarks: []parser.Benchmark{{Variations: []parser.Variation{
+		{Labels: map[string]string{"const/mapSize": "100"}},
+		{Labels: map[string]string{"const/mapSize": "10"}},
+		{Labels: map[string]string{"const/mapSize": "100", "size": "5"}},
+	}}}}
+	sweeps := groupSweeps(group)
+	if len(sweeps) != 1 || sweeps[0].Const != "mapSize" || strings.Join(sweeps[0].Values, ",") != "10,100" {
+		t.Errorf("unexpected sweeps %+v", sweeps)
+	}
+
+	if _, _, err := parseSweep("mapSize"); err == nil {
+		t.Error("expected error for sweep without values")
+	}
+}
@@ -0,0 +1,80 @@
+package commands
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// cooldownPoll is how often temperature and load are read while cooling down.
+const cooldownPoll = 500 * time.Millisecond
+
+// cooldownOptions configures the pause between runs: at least minPause,
+// then until the temperature and load are below their thresholds or
+// timeout is reached.
+type cooldownOptions struct {
+	minPause time.Duration // Pause between runs even on an idle machine
+	maxTemp  float64       // Highest thermal zone temperature to start a run at, in °C
+	maxLoad  float64       // Highest 1-minute load average per CPU to start a run at
+	timeout  time.Duration // Longest pause between runs
+}
+
+// wait pauses before a run until the machine has cooled down. It returns
+// how long it waited and whether it gave up because of the timeout.
+// Readings that are not available do not delay the run.
+func (o cooldownOptions) wait(logger *slog.Logger, h host, numCPU int) (time.Duration, bool) {
+	start := time.Now()
+	time.Sleep(o.minPause)
+
+	for {
+		conditions := h.conditions(numCPU)
+		if conditions.Temperature <= o.maxTemp && conditions.Load <= o.maxLoad {
+			return time.Since(start), false
+		}
+		if time.Since(start) >= o.timeout {
+			logger.Warn("cooldown timed out", "temperature", conditions.Temperature, "load", conditions.Load, "timeout", o.timeout)
+			return time.Since(start), true
+		}
+
+		logger.Debug("cooling down", "temperature", conditions.Temperature, "load", conditions.Load)
+		time.Sleep(min(cooldownPoll, o.timeout-time.Since(start)))
+	}
+}
+
+// conditions reads the current temperature and load per CPU. Values that
+// are not available are 0.
+func (h host) conditions(numCPU int) parser.RunConditions {
+	var conditions parser.RunConditions
+	conditions.Temperature, _ = h.temperature()
+	if load, err := h.loadAverage(); err == nil {
+		conditions.Load = load / float64(max(numCPU, 1))
+	}
+	return conditions
+}
+
+// temperature returns the highest temperature of all thermal zones in °C.
+// It reports false if no thermal zone is exposed.
+func (h host) temperature() (float64, bool) {
+	zones, _ := filepath.Glob(filepath.Join(h.root, "sys/class/thermal/thermal_zone*/temp"))
+
+	highest, ok := 0.0, false
+	for _, zone := range zones {
+		b, err := os.ReadFile(zone)
+		if err != nil {
+			continue
+		}
+		// Temperatures are in millidegrees; some zones report 0 or
+		// negative values when their sensor is not in use.
+		milli, err := strconv.ParseFloat(strings.TrimSpace(string(b)), 64)
+		if err != nil || milli <= 0 {
+			continue
+		}
+		highest, ok = max(highest, milli/1000), true
+	}
+	return highest, ok
+}
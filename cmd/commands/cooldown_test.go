@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestTemperature(t *testing.T) {
+	h := writeHostFiles(t, map[string]string{
+		"sys/class/thermal/thermal_zone0/temp": "45000",
+		"sys/class/thermal/thermal_zone1/temp": "71500",
+		"sys/class/thermal/thermal_zone2/temp": "-273000",
+	})
+	if temp, ok := h.temperature(); !ok || temp != 71.5 {
+		t.Errorf("expected 71.5°C, got %v (%v)", temp, ok)
+	}
+
+	if _, ok := (host{root: t.TempDir()}).temperature(); ok {
+		t.Error("expected no temperature without thermal zones")
+	}
+}
+
+func TestCooldownWait(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	opts := cooldownOptions{maxTemp: 70, maxLoad: 0.5, timeout: 50 * time.Millisecond}
+
+	cool := writeHostFiles(t, map[string]string{
+		"sys/class/thermal/thermal_zone0/temp": "45000",
+		"proc/loadavg":                         "0.80 0.50 0.20 1/100 1",
+	})
+	if _, timedOut := opts.wait(logger, cool, 2); timedOut {
+		t.Error("expected a cool machine not to time out")
+	}
+
+	hot := writeHostFiles(t, map[string]string{
+		"sys/class/thermal/thermal_zone0/temp": "85000",
+		"proc/loadavg":                         "0.10 0.10 0.10 1/100 1",
+	})
+	waited, timedOut := opts.wait(logger, hot, 2)
+	if !timedOut || waited < opts.timeout {
+		t.Errorf("expected a hot machine to time out after %v, waited %v (%v)", opts.timeout, waited, timedOut)
+	}
+
+	conditions := hot.conditions(2)
+	if conditions.Temperature != 85 || conditions.Load != 0.05 {
+		t.Errorf("unexpected conditions %+v", conditions)
+	}
+}
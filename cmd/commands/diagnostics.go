@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// diagnosticsGCFlags makes the compiler report inlining decisions, escape
+// analysis results and the bounds checks it could not eliminate.
+const diagnosticsGCFlags = "-gcflags=-m=2 -d=ssa/check_bce/debug=1"
+
+// diagnosticLine matches a compiler diagnostic, e.g.
+// "./counter_test.go:26:20: inlining call to (*IntCounter).increment".
+var diagnosticLine = regexp.MustCompile(`^(?:\./)?([^:\s]+\.go):(\d+):(\d+): (.*)$`)
+
+// groupCompilerNotes compiles the test binary of the group at path with
+// compiler diagnostics enabled and attributes the notes to the declarations
+// of each benchmark by file and line. The result is keyed by benchmark name.
+func groupCompilerNotes(path string, benchmarks []parser.Benchmark) (map[string][]parser.CompilerNote, error) {
+	tmp, err := os.MkdirTemp("", "gobench-diagnostics-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	config := runConfig{dir: path}
+	out, err := config.goCommand("test", "-c", "-o", filepath.Join(tmp, "bench.test"), diagnosticsGCFlags).CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build test binary: %w: %s", err, out)
+	}
+
+	notes := parseCompilerNotes(out)
+	result := make(map[string][]parser.CompilerNote)
+	for _, bench := range benchmarks {
+		for _, note := range notes {
+			for _, src := range bench.Sources {
+				if src.Package == "" && src.File == note.File && src.StartLine <= note.Line && note.Line <= src.EndLine {
+					note.Name = src.Name
+					result[bench.Name] = append(result[bench.Name], note)
+					break
+				}
+			}
+		}
+	}
+
+	for _, notes := range result {
+		slices.SortStableFunc(notes, func(a, b parser.CompilerNote) int {
+			return cmp.Or(strings.Compare(a.File, b.File), cmp.Compare(a.Line, b.Line), cmp.Compare(a.Column, b.Column))
+		})
+	}
+
+	return result, nil
+}
+
+// parseCompilerNotes extracts inlined calls, calls that cannot be inlined,
+// heap escapes and remaining bounds checks from compiler output. Other
+// diagnostics, such as the explanations printed by -m=2, are dropped.
+func parseCompilerNotes(out []byte) []parser.CompilerNote {
+	type position struct {
+		file         string
+		line, column int
+		message      string
+	}
+	seen := make(map[position]bool)
+
+	var notes []parser.CompilerNote
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		m := diagnosticLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		line, _ := strconv.Atoi(m[2])
+		column, _ := strconv.Atoi(m[3])
+		message := m[4]
+
+		var kind string
+		switch {
+		case strings.HasPrefix(message, "inlining call to "):
+			kind = "inlined"
+		case strings.HasPrefix(message, "cannot inline "):
+			kind = "not inlined"
+		case strings.HasSuffix(message, " escapes to heap"), strings.HasPrefix(message, "moved to heap: "):
+			kind = "escapes"
+		case strings.HasPrefix(message, "Found IsInBounds"), strings.HasPrefix(message, "Found IsSliceInBounds"):
+			kind = "bounds check"
+		default:
+			continue
+		}
+
+		pos := position{m[1], line, column, message}
+		if seen[pos] {
+			continue
+		}
+		seen[pos] = true
+
+		notes = append(notes, parser.CompilerNote{
+			Kind:    kind,
+			File:    m[1],
+			Line:    line,
+			Column:  column,
+			Message: message,
+		})
+	}
+
+	return notes
+}
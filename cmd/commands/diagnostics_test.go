@@ -0,0 +1,43 @@
+package commands
+
+import "testing"
+
+func TestParseCompilerNotes(t *testing.T) {
+	out := `# benchmarks/counter [benchmarks/counter.test]
+./counter_test.go:14:6: can inline (*IntCounter).increment with cost 4 as: method(*IntCounter) func() { c.count++ }
+./counter_test.go:15:6: cannot inline (*MutexCounter).increment: unhandled op DEFER
+./counter_test.go:26:20: inlining call to (*IntCounter).increment
+./counter_test.go:26:20: inlining call to (*IntCounter).increment
+./buffer_test.go:15:19: string(bytes.b.buf[bytes.b.off:]) escapes to heap in BenchmarkBuffer_write:
+./buffer_test.go:15:19:   flow: ~r0 = &{storage for string(bytes.b.buf[bytes.b.off:])}:
+./buffer_test.go:15:19: string(bytes.b.buf[bytes.b.off:]) escapes to heap
+./buffer_test.go:12:2: moved to heap: buf
+./buffer_test.go:14:7: c does not escape
+./slice_test.go:20:9: Found IsInBounds
+`
+
+	notes := parseCompilerNotes([]byte(out))
+
+	want := []struct {
+		kind string
+		line int
+	}{
+		{"not inlined", 15},
+		{"inlined", 26},
+		{"escapes", 15},
+		{"escapes", 12},
+		{"bounds check", 20},
+	}
+	if len(notes) != len(want) {
+		t.Fatalf("expected %d notes, got %d: %+v", len(want), len(notes), notes)
+	}
+	for i, w := range want {
+		if notes[i].Kind != w.kind || notes[i].Line != w.line {
+			t.Errorf("note %d: expected %s at line %d, got %+v", i, w.kind, w.line, notes[i])
+		}
+	}
+
+	if notes[1].File != "counter_test.go" || notes[1].Column != 20 || notes[1].Message != "inlining call to (*IntCounter).increment" {
+		t.Errorf("unexpected note: %+v", notes[1])
+	}
+}
@@ -0,0 +1,423 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Outcomes of an environment check.
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// Thresholds of the environment checks.
+const (
+	loadWarnPerCPU  = 0.25      // 1-minute load average per CPU above which results get noisy
+	loadFailPerCPU  = 1.0       // Load average per CPU at which benchmarks compete for CPUs
+	memoryWarnShare = 0.1       // Share of available memory below which the system may swap
+	memoryFailKiB   = 512 << 10 // Available memory below which benchmarks may not fit
+)
+
+// doctorCheckWidth is the width of the check name column of the report.
+const doctorCheckWidth = 15
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check whether this machine is set up for reliable benchmark results",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hostRoot, _ := cmd.Flags().GetString("host-root")
+
+		if runtime.GOOS != "linux" {
+			fmt.Fprintf(cmd.OutOrStdout(), "doctor only inspects Linux machines, nothing to check on %s\n", runtime.GOOS)
+			return nil
+		}
+
+		results := host{root: hostRoot}.checks(runtime.NumCPU())
+		printChecks(cmd.OutOrStdout(), results)
+
+		if failed := countChecks(results, checkFail); failed > 0 {
+			return fmt.Errorf("%d environment checks failed", failed)
+		}
+		return nil
+	},
+}
+
+// checkResult is the outcome of an environment check.
+type checkResult struct {
+	name   string
+	status string // One of checkPass, checkWarn, checkFail or checkSkip
+	detail string
+	fix    string // Remediation for warnings and failures
+}
+
+// host reads the kernel interfaces (/proc and /sys) of the machine below
+// root, which is "/" except in tests.
+type host struct {
+	root string
+}
+
+// read returns the trimmed content of the file at path, e.g. "/proc/loadavg".
+func (h host) read(path string) (string, error) {
+	b, err := os.ReadFile(filepath.Join(h.root, path))
+	return strings.TrimSpace(string(b)), err
+}
+
+// checks runs all environment checks. numCPU is the number of CPUs
+// benchmarks are run with, i.e. runtime.NumCPU().
+func (h host) checks(numCPU int) []checkResult {
+	return []checkResult{
+		h.checkGovernor(),
+		h.checkTurbo(),
+		h.checkSMT(),
+		h.checkLoad(numCPU),
+		h.checkMemory(),
+		h.checkCPUQuota(numCPU),
+		h.checkVirtualization(),
+	}
+}
+
+// checkGovernor reports CPUs whose frequency governor is not "performance",
+// which makes the clock speed depend on recent load. The "powersave"
+// governor fails the check, as it keeps the CPUs at a reduced clock speed.
+func (h host) checkGovernor() checkResult {
+	result := checkResult{name: "cpu governor"}
+
+	files, _ := filepath.Glob(filepath.Join(h.root, "sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_governor"))
+	if len(files) == 0 {
+		result.status, result.detail = checkSkip, "frequency scaling is not exposed"
+		return result
+	}
+
+	others := make(map[string]int)
+	powersave := false
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		if governor := strings.TrimSpace(string(b)); governor != "performance" {
+			others[governor]++
+			powersave = powersave || governor == "powersave"
+		}
+	}
+
+	if len(others) == 0 {
+		result.status, result.detail = checkPass, fmt.Sprintf("performance on all %d CPUs", len(files))
+		return result
+	}
+
+	var governors []string
+	for governor, n := range others {
+		governors = append(governors, fmt.Sprintf("%s on %d of %d CPUs", governor, n, len(files)))
+	}
+	slices.Sort(governors)
+	result.status = checkWarn
+	if powersave {
+		result.status = checkFail
+	}
+	result.detail = strings.Join(governors, ", ")
+	result.fix = "sudo cpupower frequency-set -g performance"
+	return result
+}
+
+// checkTurbo reports enabled turbo boost, which makes the clock speed depend
+// on temperature and the number of busy cores.
+func (h host) checkTurbo() checkResult {
+	result := checkResult{name: "turbo boost"}
+
+	if noTurbo, err := h.read("/sys/devices/system/cpu/intel_pstate/no_turbo"); err == nil {
+		if noTurbo == "1" {
+			result.status, result.detail = checkPass, "disabled"
+			return result
+		}
+		result.status, result.detail = checkWarn, "enabled"
+		result.fix = "echo 1 | sudo tee /sys/devices/system/cpu/intel_pstate/no_turbo"
+		return result
+	}
+
+	if boost, err := h.read("/sys/devices/system/cpu/cpufreq/boost"); err == nil {
+		if boost == "0" {
+			result.status, result.detail = checkPass, "disabled"
+			return result
+		}
+		result.status, result.detail = checkWarn, "enabled"
+		result.fix = "echo 0 | sudo tee /sys/devices/system/cpu/cpufreq/boost"
+		return result
+	}
+
+	result.status, result.detail = checkSkip, "turbo boost is not exposed"
+	return result
+}
+
+// checkSMT reports active simultaneous multithreading, where benchmarks on
+// sibling threads compete for the same core.
+func (h host) checkSMT() checkResult {
+	result := checkResult{name: "smt"}
+
+	active, err := h.read("/sys/devices/system/cpu/smt/active")
+	switch {
+	case err != nil:
+		result.status, result.detail = checkSkip, "smt is not exposed"
+	case active == "0":
+		result.status, result.detail = checkPass, "inactive"
+	default:
+		result.status, result.detail = checkWarn, "active, sibling threads share a core"
+		result.fix = "echo off | sudo tee /sys/devices/system/cpu/smt/control"
+	}
+	return result
+}
+
+// checkLoad reports other processes keeping the CPUs busy.
+func (h host) checkLoad(numCPU int) checkResult {
+	result := checkResult{name: "load average"}
+
+	load, err := h.loadAverage()
+	if err != nil {
+		result.status, result.detail = checkSkip, err.Error()
+		return result
+	}
+
+	perCPU := load / float64(max(numCPU, 1))
+	result.detail = fmt.Sprintf("%.2f (%.2f per CPU)", load, perCPU)
+	switch {
+	case perCPU >= loadFailPerCPU:
+		result.status = checkFail
+	case perCPU > loadWarnPerCPU:
+		result.status = checkWarn
+	default:
+		result.status = checkPass
+		return result
+	}
+	result.fix = "stop background jobs (builds, browsers, indexers) and wait for the load to settle"
+	return result
+}
+
+// loadAverage returns the 1-minute load average.
+func (h host) loadAverage() (float64, error) {
+	content, err := h.read("/proc/loadavg")
+	if err != nil {
+		return 0, fmt.Errorf("load average is not exposed")
+	}
+	fields := strings.Fields(content)
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("failed to parse /proc/loadavg")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
+
+// checkMemory reports low available memory, which makes the garbage
+// collector and the page cache behave differently than usual.
+func (h host) checkMemory() checkResult {
+	result := checkResult{name: "memory"}
+
+	content, err := h.read("/proc/meminfo")
+	if err != nil {
+		result.status, result.detail = checkSkip, "memory info is not exposed"
+		return result
+	}
+
+	values := make(map[string]int64) // in KiB
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(value)
+		if len(fields) > 0 {
+			values[key], _ = strconv.ParseInt(fields[0], 10, 64)
+		}
+	}
+
+	total, available := values["MemTotal"], values["MemAvailable"]
+	if total == 0 {
+		result.status, result.detail = checkSkip, "failed to parse /proc/meminfo"
+		return result
+	}
+
+	result.detail = fmt.Sprintf("%.1f GiB of %.1f GiB available", float64(available)/(1<<20), float64(total)/(1<<20))
+	switch {
+	case available < memoryFailKiB:
+		result.status = checkFail
+	case float64(available) < memoryWarnShare*float64(total):
+		result.status = checkWarn
+	default:
+		result.status = checkPass
+		return result
+	}
+	result.fix = "close memory-hungry applications"
+	return result
+}
+
+// checkCPUQuota reports a cgroup CPU quota below runtime.NumCPU(). Runs with
+// more CPUs than the quota allows are throttled, which makes parallel
+// benchmarks look like they scale badly.
+func (h host) checkCPUQuota(numCPU int) checkResult {
+	result := checkResult{name: "cpu quota"}
+
+	quota, ok := h.cpuQuota()
+	switch {
+	case !ok:
+		result.status, result.detail = checkSkip, "no cgroup cpu controller found"
+	case quota == 0:
+		result.status, result.detail = checkPass, "no quota"
+	case quota < float64(numCPU):
+		result.status = checkFail
+		result.detail = fmt.Sprintf("runtime.NumCPU() is %d but the cgroup quota allows %.2g CPUs", numCPU, quota)
+		result.fix = "raise the quota (e.g. docker run --cpus) or limit the CPUs with taskset or --cpuset-cpus"
+	default:
+		result.status, result.detail = checkPass, fmt.Sprintf("%.2g CPUs", quota)
+	}
+	return result
+}
+
+// cpuQuota returns the CPU quota of the process' cgroup in CPUs, or 0 for
+// no quota. It reports false if no cgroup CPU controller was found.
+func (h host) cpuQuota() (float64, bool) {
+	// cgroup v2: "<quota> <period>" in the process' cgroup, which is the
+	// root of the hierarchy inside containers.
+	var dirs []string
+	if content, err := h.read("/proc/self/cgroup"); err == nil {
+		for _, line := range strings.Split(content, "\n") {
+			if path, ok := strings.CutPrefix(line, "0::"); ok {
+				dirs = append(dirs, filepath.Join("/sys/fs/cgroup", path))
+			}
+		}
+	}
+	dirs = append(dirs, "/sys/fs/cgroup")
+	for _, dir := range dirs {
+		content, err := h.read(filepath.Join(dir, "cpu.max"))
+		if err != nil {
+			continue
+		}
+		fields := strings.Fields(content)
+		if len(fields) != 2 || fields[0] == "max" {
+			return 0, true
+		}
+		return cpuQuotaRatio(fields[0], fields[1])
+	}
+
+	// cgroup v1: quota and period in separate files, -1 for no quota.
+	quota, err := h.read("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	if quota == "-1" {
+		return 0, true
+	}
+	period, err := h.read("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return cpuQuotaRatio(quota, period)
+}
+
+func cpuQuotaRatio(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p == 0 {
+		return 0, false
+	}
+	return q / p, true
+}
+
+// checkVirtualization reports running under a hypervisor, where other
+// guests and the host steal CPU time unpredictably.
+func (h host) checkVirtualization() checkResult {
+	result := checkResult{name: "virtualization"}
+
+	cpuinfo, err := h.read("/proc/cpuinfo")
+	if err != nil {
+		result.status, result.detail = checkSkip, "cpu info is not exposed"
+		return result
+	}
+
+	virtual := false
+	for _, line := range strings.Split(cpuinfo, "\n") {
+		if key, flags, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "flags" {
+			virtual = virtual || slices.Contains(strings.Fields(flags), "hypervisor")
+		}
+	}
+	if !virtual {
+		result.status, result.detail = checkPass, "bare metal"
+		return result
+	}
+
+	result.status, result.detail = checkWarn, "running in a virtual machine"
+	if vendor, err := h.read("/sys/class/dmi/id/sys_vendor"); err == nil && vendor != "" {
+		result.detail += " (" + vendor + ")"
+	}
+	result.fix = "prefer bare metal, or use dedicated vCPUs without overcommitment"
+	return result
+}
+
+// printChecks prints the results of the environment checks with their
+// remediation, followed by a summary.
+func printChecks(w io.Writer, results []checkResult) {
+	for _, r := range results {
+		fmt.Fprintf(w, "%-4s  %-*s  %s\n", strings.ToUpper(r.status), doctorCheckWidth, r.name, r.detail)
+		if r.fix != "" && (r.status == checkWarn || r.status == checkFail) {
+			fmt.Fprintf(w, "      %-*s  fix: %s\n", doctorCheckWidth, "", r.fix)
+		}
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warned, %d failed, %d skipped\n",
+		countChecks(results, checkPass), countChecks(results, checkWarn), countChecks(results, checkFail), countChecks(results, checkSkip))
+}
+
+// preflight runs the environment checks before benchmarks are run, logging
+// warnings and failures. It returns an error if a check failed, unless force
+// is set.
+func preflight(logger *slog.Logger, hostRoot string, force bool) error {
+	if runtime.GOOS != "linux" {
+		return nil
+	}
+
+	results := host{root: hostRoot}.checks(runtime.NumCPU())
+	for _, r := range results {
+		switch r.status {
+		case checkWarn:
+			logger.Warn("environment check", "check", r.name, "detail", r.detail, "fix", r.fix)
+		case checkFail:
+			logger.Error("environment check failed", "check", r.name, "detail", r.detail, "fix", r.fix)
+		}
+	}
+
+	failed := countChecks(results, checkFail)
+	if failed > 0 && !force {
+		return fmt.Errorf("%d environment checks failed, fix them (see the doctor command) or pass --force to run anyway", failed)
+	}
+	return nil
+}
+
+// countChecks returns the number of results with the given status.
+func countChecks(results []checkResult, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.status == status {
+			n++
+		}
+	}
+	return n
+}
+
+func init() {
+	doctorCmd.Flags().String("host-root", "/", "Directory containing the /proc and /sys trees to inspect")
+
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+)
+
+// writeHostFiles creates a fake /proc and /sys tree in a temporary directory.
+func writeHostFiles(t *testing.T, files map[string]string) host {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return host{root: root}
+}
+
+func TestDoctorChecks(t *testing.T) {
+	h := writeHostFiles(t, map[string]string{
+		"sys/devices/system/cpu/cpu0/cpufreq/scaling_governor": "performance",
+		"sys/devices/system/cpu/cpu1/cpufreq/scaling_governor": "powersave",
+		"sys/devices/system/cpu/intel_pstate/no_turbo":         "1",
+		"sys/devices/system/cpu/smt/active":                    "1",
+		"proc/loadavg":                                         "3.10 2.00 1.00 2/300 1234",
+		"proc/meminfo":                                         "MemTotal:       16000000 kB\nMemFree:         1000000 kB\nMemAvailable:    8000000 kB",
+		"proc/self/cgroup":                                     "0::/docker/abc",
+		"sys/fs/cgroup/docker/abc/cpu.max":                     "200000 100000",
+		"proc/cpuinfo":                                         "processor\t: 0\nflags\t\t: fpu sse2 hypervisor",
+		"sys/class/dmi/id/sys_vendor":                          "QEMU",
+	})
+
+	want := map[string]string{
+		"cpu governor":   checkFail,
+		"turbo boost":    checkPass,
+		"smt":            checkWarn,
+		"load average":   checkWarn,
+		"memory":         checkPass,
+		"cpu quota":      checkFail,
+		"virtualization": checkWarn,
+	}
+	results := h.checks(4)
+	for _, r := range results {
+		if r.status != want[r.name] {
+			t.Errorf("%s: expected %s, got %s (%s)", r.name, want[r.name], r.status, r.detail)
+		}
+		if (r.status == checkWarn || r.status == checkFail) && r.fix == "" {
+			t.Errorf("%s: expected a fix", r.name)
+		}
+	}
+
+	var out bytes.Buffer
+	printChecks(&out, results)
+	for _, s := range []string{"powersave on 1 of 2 CPUs", "allows 2 CPUs", "(QEMU)", "2 passed, 3 warned, 2 failed, 0 skipped"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("expected %q in:\n%s", s, out.String())
+		}
+	}
+
+	// Missing interfaces are skipped rather than failed.
+	for _, r := range (host{root: t.TempDir()}).checks(4) {
+		if r.status != checkSkip {
+			t.Errorf("%s: expected skip without /proc and /sys, got %s", r.name, r.status)
+		}
+	}
+}
+
+func TestCPUQuota(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		quota float64
+		ok    bool
+	}{
+		{map[string]string{"sys/fs/cgroup/cpu.max": "max 100000"}, 0, true},
+		{map[string]string{"sys/fs/cgroup/cpu.max": "150000 100000"}, 1.5, true},
+		{map[string]string{"sys/fs/cgroup/cpu/cpu.cfs_quota_us": "-1"}, 0, true},
+		{map[string]string{"sys/fs/cgroup/cpu/cpu.cfs_quota_us": "50000", "sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000"}, 0.5, true},
+		{map[string]string{}, 0, false},
+	}
+	for _, tt := range tests {
+		quota, ok := writeHostFiles(t, tt.files).cpuQuota()
+		if quota != tt.quota || ok != tt.ok {
+			t.Errorf("%v: expected %v (%v), got %v (%v)", tt.files, tt.quota, tt.ok, quota, ok)
+		}
+	}
+}
+
+func TestPreflight(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("environment checks only run on Linux")
+	}
+
+	h := writeHostFiles(t, map[string]string{"sys/devices/system/cpu/cpu0/cpufreq/scaling_governor": "powersave"})
+	if err := preflight(logger.New(false), h.root, false); err == nil {
+		t.Error("expected the powersave governor to stop the run")
+	}
+	if err := preflight(logger.New(false), h.root, true); err != nil {
+		t.Errorf("expected --force to run despite failed checks, got %v", err)
+	}
+
+	// Checks that cannot be performed do not stop the run.
+	if err := preflight(logger.New(false), t.TempDir(), false); err != nil {
+		t.Errorf("expected skipped checks not to stop the run, got %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// flameGraphsDir is the directory inside a group that flame graphs are
+// written to, as <flameGraphsDir>/<kind>/<impl>_<behavior>.svg.
+const flameGraphsDir = "_flamegraphs"
+
+// Layout of rendered flame graphs, in pixels.
+const (
+	flameWidth      = 1200.0
+	flamePadding    = 10.0
+	flameHeader     = 40.0
+	flameFrame      = 16.0
+	flameFrameGap   = 1.0
+	flameCharWidth  = 6.6
+	flameMinPixels  = 0.1
+	flameFontFamily = "Verdana, sans-serif"
+)
+
+var flamegraphCmd = &cobra.Command{
+	Use:   "flamegraph",
+	Short: "Render flame graphs of profiles collected with \"run --profile\"",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debug, _ := cmd.Flags().GetBool("debug")
+		logger := logger.New(debug)
+
+		basePath := cmd.Flag("benchmarks").Value.String()
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return fmt.Errorf("benchmarks directory does not exist: %s", basePath)
+		}
+
+		total := 0
+		err := utils.WalkOverBenchmarks(basePath, func(path string) error {
+			n, err := renderGroupFlameGraphs(logger, path)
+			total += n
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		logger.Info("done", "flamegraphs", total)
+		return nil
+	},
+}
+
+// renderGroupFlameGraphs renders every profile stored in the group at path
+// to an SVG flame graph in the group's flameGraphsDir, replacing previously
+// rendered ones. It returns the number of flame graphs written.
+func renderGroupFlameGraphs(logger *slog.Logger, path string) (int, error) {
+	kinds, err := os.ReadDir(filepath.Join(path, profilesDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	if err := os.RemoveAll(filepath.Join(path, flameGraphsDir)); err != nil {
+		return 0, fmt.Errorf("failed to remove old flame graphs: %w", err)
+	}
+
+	count := 0
+	for _, kind := range kinds {
+		if !kind.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(path, profilesDir, kind.Name()))
+		if err != nil {
+			return count, fmt.Errorf("failed to read profiles: %w", err)
+		}
+
+		for _, entry := range entries {
+			base, ok := strings.CutSuffix(entry.Name(), ".pprof")
+			if !ok {
+				continue
+			}
+
+			name := filepath.Join(path, profilesDir, kind.Name(), entry.Name())
+			f, err := os.Open(name)
+			if err != nil {
+				return count, fmt.Errorf("failed to open profile: %w", err)
+			}
+			p, err := profile.Parse(f)
+			f.Close()
+			if err != nil {
+				return count, fmt.Errorf("failed to parse profile %s: %w", name, err)
+			}
+
+			out := filepath.Join(path, flameGraphsDir, kind.Name(), base+".svg")
+			if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+				return count, fmt.Errorf("failed to create flame graph directory: %w", err)
+			}
+
+			title := fmt.Sprintf("%s (%s)", strings.ReplaceAll(base, "_", " "), kind.Name())
+			if err := os.WriteFile(out, renderFlameGraph(p, kind.Name(), title), 0644); err != nil {
+				return count, fmt.Errorf("failed to write flame graph: %w", err)
+			}
+
+			logger.Debug("rendered flame graph", "path", out)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// flameNode is a frame of a flame graph: a function and the samples of all
+// stacks that reach it through the same callers.
+type flameNode struct {
+	name     string
+	value    int64
+	children map[string]*flameNode
+}
+
+func (n *flameNode) child(name string) *flameNode {
+	c := n.children[name]
+	if c == nil {
+		c = &flameNode{name: name, children: make(map[string]*flameNode)}
+		n.children[name] = c
+	}
+	return c
+}
+
+// sortedChildren returns the children ordered by name, which places equal
+// frames of different stacks next to each other.
+func (n *flameNode) sortedChildren() []*flameNode {
+	children := make([]*flameNode, 0, len(n.children))
+	for _, c := range n.children {
+		children = append(children, c)
+	}
+	sort.Slice(children, func(i, j int) bool { return children[i].name < children[j].name })
+	return children
+}
+
+func (n *flameNode) depth() int {
+	d := 0
+	for _, c := range n.children {
+		d = max(d, c.depth())
+	}
+	return d + 1
+}
+
+// buildFlameTree merges all stacks of a profile, root first, into a tree of
+// frames weighted by the sample value at index.
+func buildFlameTree(p *profile.Profile, index int) *flameNode {
+	root := &flameNode{name: "all", children: make(map[string]*flameNode)}
+	for _, s := range p.Sample {
+		value := s.Value[index]
+		if value == 0 {
+			continue
+		}
+		root.value += value
+
+		node := root
+		// Locations are listed leaf first, and inlined calls innermost first.
+		for i := len(s.Location) - 1; i >= 0; i-- {
+			lines := s.Location[i].Line
+			for j := len(lines) - 1; j >= 0; j-- {
+				if lines[j].Function == nil {
+					continue
+				}
+				node = node.child(lines[j].Function.Name)
+				node.value += value
+			}
+		}
+	}
+	return root
+}
+
+// renderFlameGraph renders a profile as a self-contained SVG flame graph,
+// with the root at the bottom and the width of each frame proportional to
+// its share of the total. Hovering a frame shows its full name and value.
+func renderFlameGraph(p *profile.Profile, kind, title string) []byte {
+	index := sampleTypeIndex(p, kind)
+	root := &flameNode{name: "all"}
+	unit := ""
+	if index >= 0 {
+		root = buildFlameTree(p, index)
+		unit = p.SampleType[index].Unit
+	}
+
+	depth := root.depth()
+	height := flameHeader + float64(depth)*(flameFrame+flameFrameGap) + flamePadding
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f" font-family="%s" font-size="11">`+"\n", flameWidth, height, flameWidth, height, flameFontFamily)
+	fmt.Fprintf(&b, `<rect width="100%%" height="100%%" fill="#f8f8f8"/>`+"\n")
+	fmt.Fprintf(&b, `<text x="%.0f" y="24" font-size="16" text-anchor="middle">%s</text>`+"\n", flameWidth/2, escapeXML(title))
+
+	if root.value == 0 {
+		fmt.Fprintf(&b, `<text x="%.0f" y="%.0f" text-anchor="middle">no samples</text>`+"\n", flameWidth/2, flameHeader+flameFrame)
+		b.WriteString("</svg>\n")
+		return []byte(b.String())
+	}
+
+	scale := (flameWidth - 2*flamePadding) / float64(root.value)
+	var draw func(n *flameNode, x float64, level int)
+	draw = func(n *flameNode, x float64, level int) {
+		w := float64(n.value) * scale
+		if w < flameMinPixels {
+			return
+		}
+		y := height - flamePadding - float64(level+1)*(flameFrame+flameFrameGap)
+
+		label := fmt.Sprintf("%s (%s, %.2f%%)", n.name, formatProfileValue(n.value, unit), 100*float64(n.value)/float64(root.value))
+		fmt.Fprintf(&b, `<g><title>%s</title><rect x="%.2f" y="%.0f" width="%.2f" height="%.0f" rx="2" fill="%s"/>`, escapeXML(label), x, y, w, flameFrame, flameColor(n.name, kind))
+		if text := fitLabel(shortFunctionName(n.name), w); text != "" {
+			fmt.Fprintf(&b, `<text x="%.2f" y="%.0f">%s</text>`, x+3, y+flameFrame-4, escapeXML(text))
+		}
+		b.WriteString("</g>\n")
+
+		for _, c := range n.sortedChildren() {
+			draw(c, x, level+1)
+			x += float64(c.value) * scale
+		}
+	}
+	draw(root, flamePadding, 0)
+
+	b.WriteString("</svg>\n")
+	return []byte(b.String())
+}
+
+// shortFunctionName strips the import path from a function name, e.g.
+// "benchmarks/counter.(*IntCounter).increment" becomes
+// "counter.(*IntCounter).increment".
+func shortFunctionName(name string) string {
+	// Type arguments of generic functions may contain import paths as well.
+	prefix, _, _ := strings.Cut(name, "[")
+	return name[strings.LastIndex(prefix, "/")+1:]
+}
+
+// fitLabel truncates text to fit into width pixels, or returns "" if not even
+// a few characters fit.
+func fitLabel(text string, width float64) string {
+	chars := int((width - 6) / flameCharWidth)
+	if chars < 3 {
+		return ""
+	}
+	runes := []rune(text)
+	if len(runes) <= chars {
+		return text
+	}
+	return string(runes[:chars-2]) + ".."
+}
+
+// flameColor returns a stable color for a function: warm colors for CPU,
+// blocking and mutex profiles and cool colors for memory profiles.
+func flameColor(name, kind string) string {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	sum := h.Sum32()
+	v1, v2, v3 := float64(sum&0xff)/255, float64(sum>>8&0xff)/255, float64(sum>>16&0xff)/255
+
+	if kind == "mem" {
+		return fmt.Sprintf("rgb(%d,%d,%d)", 0, 190+int(50*v2), int(210*v1))
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", 205+int(50*v3), int(230*v1), int(55*v2))
+}
+
+// formatProfileValue formats a sample value in a human readable way.
+func formatProfileValue(value int64, unit string) string {
+	switch unit {
+	case "nanoseconds":
+		return time.Duration(value).String()
+	case "bytes":
+		v := float64(value)
+		for _, suffix := range []string{"B", "kB", "MB", "GB"} {
+			if v < 1000 || suffix == "GB" {
+				return fmt.Sprintf("%.1f %s", v, suffix)
+			}
+			v /= 1000
+		}
+	}
+	return fmt.Sprintf("%d %s", value, unit)
+}
+
+// escapeXML escapes text for use in SVG text content and attributes.
+func escapeXML(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(text)
+}
+
+func init() {
+	flamegraphCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
+
+	rootCmd.AddCommand(flamegraphCmd)
+}
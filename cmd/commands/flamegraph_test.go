@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRenderFlameGraph(t *testing.T) {
+	p := cpuTestProfile()
+
+	root := buildFlameTree(p, sampleTypeIndex(p, "cpu"))
+	if root.value != 450 || root.depth() != 4 {
+		t.Fatalf("expected a tree of depth 4 with value 450, got depth %d with value %d", root.depth(), root.value)
+	}
+	increment := root.children["BenchmarkMutexCounter_increment"].children["(*MutexCounter).increment"]
+	if increment == nil || increment.value != 400 {
+		t.Fatalf("expected increment frame with value 400, got %+v", increment)
+	}
+
+	svg := string(renderFlameGraph(p, "cpu", "MutexCounter increment (cpu)"))
+	if !strings.HasPrefix(svg, "<?xml") || !strings.HasSuffix(svg, "</svg>\n") {
+		t.Error("expected a complete SVG document")
+	}
+	if !strings.Contains(svg, "<title>sync.(*Mutex).Lock (300ns, 66.67%)</title>") {
+		t.Errorf("expected tooltip of Lock frame, got:\n%s", svg)
+	}
+}
+
+func TestFitLabel(t *testing.T) {
+	width := 6 + 8*flameCharWidth // room for 8 characters
+	if got := fitLabel("short", width); got != "short" {
+		t.Errorf("expected the label to fit, got %q", got)
+	}
+	if got := fitLabel("encoding/json.Marshal", width); got != "encodi.." {
+		t.Errorf("expected a truncated label, got %q", got)
+	}
+	// Multi-byte characters are truncated as a whole.
+	if got := fitLabel("größenordnung", width); got != "größen.." || !utf8.ValidString(got) {
+		t.Errorf("expected truncation by characters, got %q", got)
+	}
+	if got := fitLabel("größenordnung", 6); got != "" {
+		t.Errorf("expected no label for a narrow frame, got %q", got)
+	}
+}
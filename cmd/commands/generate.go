@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/marvinjwendt/gobench/cmd/internal/logger"
 	"github.com/marvinjwendt/gobench/cmd/internal/parser"
@@ -17,48 +19,118 @@ type variationKey struct {
 	VariationName string
 	N             int
 	CPUCount      int
+	GoVersion     string
+	Variant       string
+	Labels        string // Canonical form of Variation.Labels, see labelsKey
+}
+
+// labelsKey returns a canonical, comparable representation of variation labels.
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k + "=" + labels[k] + "\n")
+	}
+	return b.String()
+}
+
+// variationSeries is a group of variations that share a key, e.g. the
+// results of one behavior under one build across the benchtime sweep.
+type variationSeries[K comparable] struct {
+	key        K
+	labels     map[string]string // Labels of the first variation of the series
+	variations []parser.Variation
+}
+
+// groupSeries groups variations by key and returns the series sorted by
+// compare. Series with equal keys by compare keep the order in which they
+// first appear.
+func groupSeries[K comparable](variations []parser.Variation, key func(parser.Variation) K, compare func(a, b K) int) []variationSeries[K] {
+	index := make(map[K]int)
+	var series []variationSeries[K]
+	for _, v := range variations {
+		k := key(v)
+		i, ok := index[k]
+		if !ok {
+			i = len(series)
+			index[k] = i
+			series = append(series, variationSeries[K]{key: k, labels: v.Labels})
+		}
+		series[i].variations = append(series[i].variations, v)
+	}
+
+	slices.SortStableFunc(series, func(a, b variationSeries[K]) int { return compare(a.key, b.key) })
+	return series
 }
 
 // medianVariations collapses duplicate variations (produced by multiple
-// benchmark runs) into a single entry per unique key by taking the
-// median of the numeric fields. Median is preferred over mean because
-// benchmark data is prone to outlier spikes (GC, scheduling, etc.).
+// benchmark runs) of the group and of every merged machine into a single
+// entry per unique key by taking the median of the numeric fields. Median is
+// preferred over mean because benchmark data is prone to outlier spikes (GC,
+// scheduling, etc.).
 func medianVariations(group *parser.BenchmarkGroup) {
-	for i, bench := range group.Benchmarks {
-		grouped := make(map[variationKey][]parser.Variation)
-		var order []variationKey
-
-		for _, v := range bench.Variations {
-			key := variationKey{
-				BenchmarkName: v.Benchmark.Name,
-				VariationName: v.Name,
-				N:             v.Benchmark.N,
-				CPUCount:      v.CPUCount,
-			}
-			if _, exists := grouped[key]; !exists {
-				order = append(order, key)
-			}
-			grouped[key] = append(grouped[key], v)
+	for i := range group.Benchmarks {
+		group.Benchmarks[i].Variations = medianOf(group.Benchmarks[i].Variations)
+	}
+	for i := range group.Machines {
+		for j := range group.Machines[i].Benchmarks {
+			group.Machines[i].Benchmarks[j].Variations = medianOf(group.Machines[i].Benchmarks[j].Variations)
 		}
+	}
+}
 
-		result := make([]parser.Variation, 0, len(order))
-		for _, key := range order {
-			vars := grouped[key]
+// medianOf collapses the variations of a benchmark by variationKey.
+func medianOf(variations []parser.Variation) []parser.Variation {
+	grouped := make(map[variationKey][]parser.Variation)
+	var order []variationKey
 
-			// Use first entry as base (preserves Name, N, Measured, etc.)
-			med := vars[0]
+	for _, v := range variations {
+		key := variationKey{
+			BenchmarkName: v.Benchmark.Name,
+			VariationName: v.Name,
+			N:             v.Benchmark.N,
+			CPUCount:      v.CPUCount,
+			GoVersion:     v.GoVersion,
+			Variant:       v.Variant,
+			Labels:        labelsKey(v.Labels),
+		}
+		if _, exists := grouped[key]; !exists {
+			order = append(order, key)
+		}
+		grouped[key] = append(grouped[key], v)
+	}
 
-			med.NsPerOp = medianFloat(vars, func(v parser.Variation) float64 { return v.NsPerOp })
-			med.MBPerS = medianFloat(vars, func(v parser.Variation) float64 { return v.MBPerS })
-			med.AllocedBytesPerOp = medianUint64(vars, func(v parser.Variation) uint64 { return v.AllocedBytesPerOp })
-			med.AllocsPerOp = medianUint64(vars, func(v parser.Variation) uint64 { return v.AllocsPerOp })
-			med.OpsPerSec = 1e9 / med.NsPerOp
+	result := make([]parser.Variation, 0, len(order))
+	for _, key := range order {
+		vars := grouped[key]
 
-			result = append(result, med)
+		// Use first entry as base (preserves Name, N, Measured, etc.)
+		med := vars[0]
+
+		med.NsPerOp = medianFloat(vars, func(v parser.Variation) float64 { return v.NsPerOp })
+		med.MBPerS = medianFloat(vars, func(v parser.Variation) float64 { return v.MBPerS })
+		med.AllocedBytesPerOp = medianUint64(vars, func(v parser.Variation) uint64 { return v.AllocedBytesPerOp })
+		med.AllocsPerOp = medianUint64(vars, func(v parser.Variation) uint64 { return v.AllocsPerOp })
+		med.OpsPerSec = 1e9 / med.NsPerOp
+		med.Runs = len(vars)
+
+		nsPerOp := make([]float64, len(vars))
+		for i, v := range vars {
+			nsPerOp[i] = v.NsPerOp
+		}
+		if width, ok := relativeMedianCI(nsPerOp); ok {
+			med.MedianCI = width
 		}
 
-		group.Benchmarks[i].Variations = result
+		result = append(result, med)
 	}
+
+	return result
 }
 
 // medianFloat returns the median of a float64 field extracted from a slice of variations.
@@ -105,7 +177,18 @@ var generateCmd = &cobra.Command{
 			return fmt.Errorf("benchmarks directory does not exist: %s", benchmarksDir)
 		}
 
-		groups, err := parser.ProcessBenchmarkGroups(logger, benchmarksDir)
+		inlineHelpers, _ := cmd.Flags().GetBool("inline-helpers")
+		changeThreshold, _ := cmd.Flags().GetFloat64("change-threshold")
+		profileTop, _ := cmd.Flags().GetInt("profile-top")
+		flameGraphs, _ := cmd.Flags().GetBool("flamegraphs")
+		asm, _ := cmd.Flags().GetBool("asm")
+		compilerNotes, _ := cmd.Flags().GetBool("compiler-notes")
+		linearityThreshold, _ := cmd.Flags().GetFloat64("linearity-threshold")
+		normalize, _ := cmd.Flags().GetBool("normalize")
+
+		groups, err := parser.ProcessBenchmarkGroups(logger, benchmarksDir, parser.ProcessOptions{
+			InlineHelpers: inlineHelpers,
+		})
 		if err != nil {
 			return fmt.Errorf("failed to process benchmark groups: %w", err)
 		}
@@ -116,6 +199,65 @@ var generateCmd = &cobra.Command{
 			medianVariations(&groups[i])
 			totalBenchmarks += len(groups[i].Benchmarks)
 
+			if flameGraphs {
+				if _, err := renderGroupFlameGraphs(logger, groups[i].Dir); err != nil {
+					return fmt.Errorf("failed to render flame graphs of %s: %w", groups[i].Name, err)
+				}
+			}
+
+			if asm {
+				assembly, err := groupAssembly(groups[i].Dir, groups[i].Benchmarks)
+				if err != nil {
+					return fmt.Errorf("failed to extract assembly of %s: %w", groups[i].Name, err)
+				}
+				for j := range groups[i].Benchmarks {
+					groups[i].Benchmarks[j].Assembly = assembly[groups[i].Benchmarks[j].Name]
+				}
+			}
+
+			if compilerNotes {
+				notes, err := groupCompilerNotes(groups[i].Dir, groups[i].Benchmarks)
+				if err != nil {
+					return fmt.Errorf("failed to collect compiler notes of %s: %w", groups[i].Name, err)
+				}
+				for j := range groups[i].Benchmarks {
+					groups[i].Benchmarks[j].CompilerNotes = notes[groups[i].Benchmarks[j].Name]
+				}
+			}
+
+			groups[i].Run, err = loadRunRecord(filepath.Join(groups[i].Dir, runRecordFile))
+			if err != nil {
+				return fmt.Errorf("failed to load run record of %s: %w", groups[i].Name, err)
+			}
+			for k, machine := range groups[i].Machines {
+				groups[i].Machines[k].Run, err = loadRunRecord(filepath.Join(groups[i].Dir, machineRunRecordFile(machine.Machine)))
+				if err != nil {
+					return fmt.Errorf("failed to load run record of %s on machine %s: %w", groups[i].Name, machine.Machine, err)
+				}
+			}
+			if normalize {
+				normalizeVariations(&groups[i])
+			}
+
+			groups[i].GoVersions = groupGoVersions(groups[i])
+			groups[i].Variants = groupVariants(groups[i])
+			groups[i].Crossovers = crossovers(groups[i])
+			groups[i].Sweeps = groupSweeps(groups[i])
+			groups[i].Consistency = winnerConsistency(groups[i])
+			for j := range groups[i].Benchmarks {
+				groups[i].Benchmarks[j].GoVersionChanges = goVersionChanges(groups[i].Benchmarks[j], changeThreshold)
+				groups[i].Benchmarks[j].Variants = variantResults(groups[i].Benchmarks[j])
+				groups[i].Benchmarks[j].CostFits = costFits(groups[i].Benchmarks[j], linearityThreshold)
+				groups[i].Benchmarks[j].Scaling = scaling(groups[i].Benchmarks[j])
+				groups[i].Benchmarks[j].Complexity = complexityFits(groups[i].Benchmarks[j])
+
+				name := strings.ReplaceAll(groups[i].Benchmarks[j].Name, " ", "")
+				groups[i].Benchmarks[j].Profiles, err = benchmarkProfiles(groups[i].Dir, name, profileTop)
+				if err != nil {
+					return fmt.Errorf("failed to summarise profiles of %s: %w", groups[i].Name, err)
+				}
+			}
+
 			j, err := parser.GenerateGroupJson(groups[i], true)
 			if err != nil {
 				return fmt.Errorf("failed to generate json for %s: %w", groups[i].Name, err)
@@ -137,6 +279,14 @@ var generateCmd = &cobra.Command{
 
 func init() {
 	generateCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
+	generateCmd.Flags().Float64("change-threshold", 0.1, "Minimum relative ns/op change between Go versions to report (0.1 = 10%)")
+	generateCmd.Flags().Bool("asm", false, "Include the disassembled machine code of each implementation (builds every group's test binary)")
+	generateCmd.Flags().Bool("compiler-notes", false, "Include inlining, escape analysis and bounds check diagnostics of each implementation (builds every group's test binary)")
+	generateCmd.Flags().Bool("flamegraphs", false, "Render flame graphs of collected profiles (same as the flamegraph command)")
+	generateCmd.Flags().Int("profile-top", 10, "Number of top functions by flat and by cumulative value to keep from each profile")
+	generateCmd.Flags().Float64("linearity-threshold", 0.95, "Minimum R² of the per-op cost regression for a behavior to count as linear")
+	generateCmd.Flags().Bool("normalize", false, "Add ns/op divided by the score of the machine each group ran on (see the calibrate command)")
+	generateCmd.Flags().Bool("inline-helpers", false, "Include referenced code from shared helper packages (benchmarks/internal) in the benchmark code")
 
 	rootCmd.AddCommand(generateCmd)
 }
@@ -0,0 +1,42 @@
+package commands
+
+import (
+	"cmp"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestGroupSeries(t *testing.T) {
+	variation := func(name string, cpu int, labels map[string]string) parser.Variation {
+		return parser.Variation{Name: name, CPUCount: cpu, Labels: labels}
+	}
+	variations := []parser.Variation{
+		variation("write", 1, nil),
+		variation("read", 4, map[string]string{"size": "10"}),
+		variation("read", 1, nil),
+		variation("write", 1, nil),
+		variation("read", 4, map[string]string{"size": "10"}),
+	}
+
+	series := groupSeries(variations,
+		func(v parser.Variation) string { return v.Name },
+		func(a, b string) int { return cmp.Compare(a, b) })
+	if len(series) != 2 || series[0].key != "read" || series[1].key != "write" {
+		t.Fatalf("expected read and write series, got %+v", series)
+	}
+	if len(series[0].variations) != 3 || series[0].variations[1].CPUCount != 1 {
+		t.Errorf("expected the variations of read in order, got %+v", series[0].variations)
+	}
+	if series[0].labels["size"] != "10" {
+		t.Errorf("expected the labels of the first variation, got %v", series[0].labels)
+	}
+
+	// Series with equal keys by compare keep their order of appearance.
+	series = groupSeries(variations,
+		func(v parser.Variation) string { return v.Name + labelsKey(v.Labels) },
+		func(a, b string) int { return 0 })
+	if len(series) != 3 || series[0].key != "write" || series[1].key != "readsize=10\n" || series[2].key != "read" {
+		t.Errorf("expected series in order of appearance, got %+v", series)
+	}
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"cmp"
+	"go/version"
+	"math"
+	"slices"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// compareGoVersions orders Go versions oldest first. Versions that cannot be
+// compared (e.g. development builds) sort last, by name.
+func compareGoVersions(a, b string) int {
+	validA, validB := version.IsValid(a), version.IsValid(b)
+	switch {
+	case validA && validB:
+		return version.Compare(a, b)
+	case validA:
+		return -1
+	case validB:
+		return 1
+	}
+
+	if a < b {
+		return -1
+	}
+	if a > b {
+		return 1
+	}
+	return 0
+}
+
+// groupGoVersions returns all Go versions a group was run with, oldest first.
+func groupGoVersions(group parser.BenchmarkGroup) []string {
+	seen := make(map[string]bool)
+	var versions []string
+	for _, bench := range group.Benchmarks {
+		for _, v := range bench.Variations {
+			if v.GoVersion != "" && !seen[v.GoVersion] {
+				seen[v.GoVersion] = true
+				versions = append(versions, v.GoVersion)
+			}
+		}
+	}
+
+	slices.SortFunc(versions, compareGoVersions)
+	return versions
+}
+
+// goVersionChanges compares the median ns/op of every behavior, CPU count and
+// label combination between consecutive Go versions and returns the changes
+// whose relative size is at least threshold.
+func goVersionChanges(bench parser.Benchmark, threshold float64) []parser.GoVersionChange {
+	type seriesKey struct {
+		Variation string
+		CPUCount  int
+		Labels    string
+	}
+
+	// Build variants are compared against the baseline separately.
+	var built []parser.Variation
+	for _, v := range bench.Variations {
+		if v.GoVersion != "" && v.Variant == "" {
+			built = append(built, v)
+		}
+	}
+
+	series := groupSeries(built,
+		func(v parser.Variation) seriesKey {
+			return seriesKey{Variation: v.Name, CPUCount: v.CPUCount, Labels: labelsKey(v.Labels)}
+		},
+		func(a, b seriesKey) int {
+			return cmp.Or(cmp.Compare(a.Variation, b.Variation), cmp.Compare(a.CPUCount, b.CPUCount))
+		})
+
+	var changes []parser.GoVersionChange
+	for _, vs := range series {
+		key := vs.key
+		byVersion := make(map[string][]parser.Variation)
+		for _, v := range vs.variations {
+			byVersion[v.GoVersion] = append(byVersion[v.GoVersion], v)
+		}
+
+		versions := make([]string, 0, len(byVersion))
+		for v := range byVersion {
+			versions = append(versions, v)
+		}
+		slices.SortFunc(versions, compareGoVersions)
+
+		for i := 1; i < len(versions); i++ {
+			from := medianFloat(byVersion[versions[i-1]], func(v parser.Variation) float64 { return v.NsPerOp })
+			to := medianFloat(byVersion[versions[i]], func(v parser.Variation) float64 { return v.NsPerOp })
+			if from == 0 {
+				continue
+			}
+
+			change := (to - from) / from
+			if math.Abs(change) < threshold {
+				continue
+			}
+
+			changes = append(changes, parser.GoVersionChange{
+				Variation:   key.Variation,
+				CPUCount:    key.CPUCount,
+				Labels:      vs.labels,
+				From:        versions[i-1],
+				To:          versions[i],
+				NsPerOpFrom: from,
+				NsPerOpTo:   to,
+				Change:      change,
+			})
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestGoVersionChanges(t *testing.T) {
+	variation := func(goVersion string, n int, nsPerOp float64) parser.Variation {
+		v := parser.Variation{Name: "run", CPUCount: 1, GoVersion: goVersion}
+		v.N = n
+		v.NsPerOp = nsPerOp
+		return v
+	}
+
+	bench := parser.Benchmark{Variations: []parser.Variation{
+		variation("go1.24.0", 1000, 50),
+		variation("go1.22.5", 1000, 100),
+		variation("go1.22.5", 2000, 100),
+		variation("go1.23.0", 1000, 98),
+		variation("go1.24.0", 2000, 50),
+	}}
+
+	changes := goVersionChanges(bench, 0.1)
+	if len(changes) != 1 {
+		t.Fatalf("expected 1 change, got %d: %+v", len(changes), changes)
+	}
+
+	c := changes[0]
+	if c.From != "go1.23.0" || c.To != "go1.24.0" {
+		t.Errorf("expected change from go1.23.0 to go1.24.0, got %s to %s", c.From, c.To)
+	}
+	if c.Change > -0.48 || c.Change < -0.49 {
+		t.Errorf("expected change of about -0.49, got %f", c.Change)
+	}
+}
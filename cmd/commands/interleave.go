@@ -0,0 +1,103 @@
+package commands
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// benchInvocation is a single benchmark function of a config, run at one CPU
+// count and benchtime from the config's prebuilt test binary.
+type benchInvocation struct {
+	config    runConfig
+	binary    string
+	benchmark string
+	cpu       string
+	benchtime string
+}
+
+// command returns the command that runs the invocation.
+func (inv benchInvocation) command() *exec.Cmd {
+	cmd := exec.Command(inv.binary,
+		"-test.run", "^$",
+		"-test.bench", "^"+inv.benchmark+"$",
+		"-test.benchmem",
+		"-test.benchtime", inv.benchtime,
+		"-test.cpu", inv.cpu,
+	)
+	cmd.Dir = inv.config.dir
+	cmd.Env = append(moduleEnv(inv.config.dir), inv.config.env...)
+	return cmd
+}
+
+// interleavedInvocations builds the test binary of every config into dir
+// and returns one invocation per config, benchmark function, CPU count and
+// benchtime, in a stable order that shuffleInvocations permutes.
+func interleavedInvocations(configs []runConfig, cpus, benchtimes []string, dir string) ([]benchInvocation, error) {
+	var invocations []benchInvocation
+	for i, config := range configs {
+		binary := filepath.Join(dir, fmt.Sprintf("%d.test", i))
+		if out, err := config.goCommand("test", "-c", "-o", binary).CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("failed to build test binary: %w: %s", err, out)
+		}
+
+		cmd := exec.Command(binary, "-test.list", "^Benchmark")
+		cmd.Dir = config.dir
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list benchmarks: %w", err)
+		}
+
+		for _, benchmark := range parseBenchmarkList(out) {
+			for _, cpu := range cpus {
+				for _, benchtime := range benchtimes {
+					invocations = append(invocations, benchInvocation{
+						config:    config,
+						binary:    binary,
+						benchmark: benchmark,
+						cpu:       cpu,
+						benchtime: benchtime,
+					})
+				}
+			}
+		}
+	}
+
+	return invocations, nil
+}
+
+// shuffleInvocations returns the invocations in random order. Each run is
+// shuffled anew, so slow phases of the machine (thermal throttling,
+// background load) spread evenly over all results instead of penalising
+// whichever benchmark runs last.
+func shuffleInvocations(invocations []benchInvocation, rng *rand.Rand) []benchInvocation {
+	shuffled := slices.Clone(invocations)
+	rng.Shuffle(len(shuffled), func(i, j int) {
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	})
+	return shuffled
+}
+
+// newSeed returns a random seed for the run order. Seeds stay below 2^53 so
+// they survive the round trip through JSON numbers in the UI.
+func newSeed() int64 {
+	return rand.Int64N(1 << 53)
+}
+
+// parseBenchmarkList extracts the benchmark names from the output of
+// "go test -list".
+func parseBenchmarkList(out []byte) []string {
+	var benchmarks []string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if name := strings.TrimSpace(scanner.Text()); strings.HasPrefix(name, "Benchmark") {
+			benchmarks = append(benchmarks, name)
+		}
+	}
+	return benchmarks
+}
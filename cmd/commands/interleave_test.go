@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"math/rand/v2"
+	"slices"
+	"testing"
+)
+
+func TestShuffleInvocations(t *testing.T) {
+	var invocations []benchInvocation
+	for _, benchmark := range []string{"BenchmarkA_run", "BenchmarkB_run"} {
+		for _, cpu := range []string{"1", "2", "4"} {
+			invocations = append(invocations, benchInvocation{benchmark: benchmark, cpu: cpu, benchtime: "1000x"})
+		}
+	}
+
+	order := func(seed int64) []string {
+		rng := rand.New(rand.NewPCG(uint64(seed), 0))
+		var runs []string
+		for range 3 {
+			for _, inv := range shuffleInvocations(invocations, rng) {
+				runs = append(runs, inv.benchmark+"-"+inv.cpu)
+			}
+		}
+		return runs
+	}
+
+	first := order(42)
+	if !slices.Equal(first, order(42)) {
+		t.Error("expected the same order for the same seed")
+	}
+	if slices.Equal(first, order(43)) {
+		t.Error("expected a different order for a different seed")
+	}
+	if slices.Equal(first[:6], first[6:12]) && slices.Equal(first[6:12], first[12:]) {
+		t.Error("expected every run to be shuffled anew")
+	}
+
+	// Every run contains each invocation exactly once.
+	run := slices.Clone(first[:6])
+	slices.Sort(run)
+	want := []string{"BenchmarkA_run-1", "BenchmarkA_run-2", "BenchmarkA_run-4", "BenchmarkB_run-1", "BenchmarkB_run-2", "BenchmarkB_run-4"}
+	if !slices.Equal(run, want) {
+		t.Errorf("expected %v, got %v", want, run)
+	}
+	if invocations[0].benchmark != "BenchmarkA_run" || invocations[0].cpu != "1" {
+		t.Error("expected the invocations not to be modified")
+	}
+}
+
+func TestParseBenchmarkList(t *testing.T) {
+	out := []byte("BenchmarkA_run\nBenchmarkB_run\nok  \tbenchmarks/counter\t0.003s\n")
+	if got := parseBenchmarkList(out); !slices.Equal(got, []string{"BenchmarkA_run", "BenchmarkB_run"}) {
+		t.Errorf("unexpected benchmarks %v", got)
+	}
+}
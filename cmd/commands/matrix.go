@@ -0,0 +1,587 @@
+package commands
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"go/version"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+	"github.com/marvinjwendt/gobench/cmd/internal/utils"
+	"golang.org/x/mod/modfile"
+)
+
+// runConfig is a single cell of the run matrix: one way of building and
+// running a benchmark group. The output of every cell is preceded by its
+// labels, so that results can be told apart when generating.
+type runConfig struct {
+	dir       string            // Directory to run go test in, set by prepareRunConfigs
+	goBin     string            // Go command to use, "go" from PATH if empty
+	goVersion string            // Version of goBin; go.mod is lowered to it if needed
+	requires  map[string]string // Module versions to pin in go.mod
+	consts    map[string]string // Values of constants to rewrite in the group
+	env       []string          // Additional environment variables
+	flags     []string          // Additional build flags, e.g. "-gcflags=-B"
+	labels    map[string]string // Labels written before the output of this cell
+}
+
+// withLabel returns a copy of c with an additional label.
+func (c runConfig) withLabel(key, value string) runConfig {
+	c.labels = maps.Clone(c.labels)
+	if c.labels == nil {
+		c.labels = make(map[string]string)
+	}
+	c.labels[key] = value
+	c.env = slices.Clone(c.env)
+	c.flags = slices.Clone(c.flags)
+	return c
+}
+
+// goCommand returns a go command that runs in the cell's directory with the
+// cell's toolchain, environment and build flags. The first argument is the
+// go subcommand, e.g. "test".
+func (c runConfig) goCommand(args ...string) *exec.Cmd {
+	if len(args) > 0 && len(c.flags) > 0 {
+		args = slices.Concat(args[:1], c.flags, args[1:])
+	}
+	cmd := exec.Command(cmp.Or(c.goBin, "go"), args...)
+	cmd.Dir = c.dir
+	cmd.Env = append(moduleEnv(c.dir), c.env...)
+	return cmd
+}
+
+// runFileConfig is the content of a _run.yml file. The file in the
+// benchmarks directory sets defaults for all groups; a group's own file
+// overrides them.
+type runFileConfig struct {
+	// Dependencies lists module versions to benchmark against, by module path.
+	Dependencies map[string][]string `yaml:"dependencies"`
+	// Go lists the toolchains to run with, e.g. "go1.22.5" (see resolveToolchains).
+	Go []string `yaml:"go"`
+	// GoRoots lists additional GOROOT directories to search for toolchains.
+	GoRoots []string `yaml:"goroots"`
+	// Variants lists named build variants to run besides the baseline build, by name.
+	Variants map[string]buildVariant `yaml:"variants"`
+	// PGO additionally runs the group with profile-guided optimization (see expandPGO).
+	PGO bool `yaml:"pgo"`
+	// Sizes runs the group once per input size.
+	Sizes sizeSweep `yaml:"sizes"`
+	// Sweeps lists values to run the group with, by constant name.
+	Sweeps map[string][]string `yaml:"sweeps"`
+}
+
+// sizeSweep declares the input sizes to run a group with, by setting the
+// constant the group's benchmarks use as input size.
+type sizeSweep struct {
+	// Const is the name of the constant, e.g. "size".
+	Const string `yaml:"const"`
+	// Values lists the sizes to run with.
+	Values []int `yaml:"values"`
+}
+
+// parseSizeFlag parses a --size value of the form "const=n1,n2,...".
+func parseSizeFlag(value string) (sizeSweep, error) {
+	if value == "" {
+		return sizeSweep{}, nil
+	}
+
+	name, values, err := parseSweep(value)
+	if err != nil {
+		return sizeSweep{}, err
+	}
+
+	sweep := sizeSweep{Const: name}
+	for _, v := range values {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return sizeSweep{}, fmt.Errorf("invalid size %q: %w", v, err)
+		}
+		sweep.Values = append(sweep.Values, n)
+	}
+	return sweep, nil
+}
+
+// buildVariant is a named way of building a group, e.g. with GOAMD64=v3 or
+// with bounds checks disabled.
+type buildVariant struct {
+	// Env lists environment variables, e.g. "GOEXPERIMENT=swissmap".
+	Env []string `yaml:"env"`
+	// Flags lists build flags passed to go test, e.g. "-gcflags=all=-B".
+	Flags []string `yaml:"flags"`
+}
+
+// loadRunFileConfig reads the _run.yml in dir. A missing file yields an
+// empty configuration.
+func loadRunFileConfig(dir string) (runFileConfig, error) {
+	var config runFileConfig
+
+	data, err := os.ReadFile(filepath.Join(dir, "_run.yml"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return config, fmt.Errorf("failed to read run config: %w", err)
+	}
+
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to decode run config: %w", err)
+	}
+	for name := range config.Variants {
+		if err := checkVariantName(name); err != nil {
+			return config, fmt.Errorf("invalid run config: %w", err)
+		}
+	}
+
+	return config, nil
+}
+
+// parseSweepFlags parses --sweep values of the form "name=v1,v2,...".
+func parseSweepFlags(values []string) (map[string][]string, error) {
+	sweeps := make(map[string][]string)
+	for _, value := range values {
+		name, values, err := parseSweep(value)
+		if err != nil {
+			return nil, err
+		}
+		sweeps[name] = values
+	}
+
+	return sweeps, nil
+}
+
+// parseDependencyFlags parses --dep values of the form "module@v1,v2,...".
+func parseDependencyFlags(values []string) (map[string][]string, error) {
+	deps := make(map[string][]string)
+	for _, value := range values {
+		module, versions, ok := strings.Cut(value, "@")
+		if !ok || module == "" || versions == "" {
+			return nil, fmt.Errorf("invalid dependency %q, expected module@version[,version...]", value)
+		}
+		deps[module] = strings.Split(versions, ",")
+	}
+
+	return deps, nil
+}
+
+// parseVariantFlags parses --variant values of the form "name=spec", where
+// spec is a space separated list of environment variables (KEY=value) and
+// build flags (starting with "-").
+func parseVariantFlags(values []string) (map[string]buildVariant, error) {
+	variants := make(map[string]buildVariant)
+	for _, value := range values {
+		name, spec, ok := strings.Cut(value, "=")
+		if !ok || name == "" || strings.TrimSpace(spec) == "" {
+			return nil, fmt.Errorf("invalid variant %q, expected name=KEY=value or name=-flag", value)
+		}
+		if err := checkVariantName(name); err != nil {
+			return nil, fmt.Errorf("invalid variant %q: %w", value, err)
+		}
+
+		var variant buildVariant
+		for _, field := range strings.Fields(spec) {
+			switch {
+			case strings.HasPrefix(field, "-"):
+				variant.Flags = append(variant.Flags, field)
+			case strings.Contains(field, "="):
+				variant.Env = append(variant.Env, field)
+			default:
+				return nil, fmt.Errorf("invalid variant %q: %q is neither KEY=value nor a flag", value, field)
+			}
+		}
+		variants[name] = variant
+	}
+
+	return variants, nil
+}
+
+// requiredModules returns the module paths required by the go.mod of a
+// group, or nil if the group has no go.mod of its own.
+func requiredModules(path string) (map[string]bool, error) {
+	name := filepath.Join(path, "go.mod")
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+
+	required := make(map[string]bool)
+	for _, r := range f.Require {
+		required[r.Mod.Path] = true
+	}
+	return required, nil
+}
+
+// labelLines formats labels as benchmark output configuration lines.
+func labelLines(labels map[string]string) []byte {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s%s: %s\n", parser.LabelPrefix, k, labels[k])
+	}
+	return []byte(b.String())
+}
+
+// expandDependencies returns a copy of every config for each combination of
+// the given dependency versions, labelled "dep/<module>: <version>".
+func expandDependencies(configs []runConfig, deps map[string][]string) []runConfig {
+	modules := make([]string, 0, len(deps))
+	for module := range deps {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+
+	// Build the cartesian product of all module versions.
+	for _, module := range modules {
+		var next []runConfig
+		for _, config := range configs {
+			for _, v := range deps[module] {
+				c := config.withLabel("dep/"+module, v)
+				c.requires = maps.Clone(c.requires)
+				if c.requires == nil {
+					c.requires = make(map[string]string)
+				}
+				c.requires[module] = v
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+
+	return configs
+}
+
+// expandSizes returns a copy of every config for each size of the sweep,
+// labelled "size: <n>".
+func expandSizes(configs []runConfig, sweep sizeSweep) []runConfig {
+	if sweep.Const == "" || len(sweep.Values) == 0 {
+		return configs
+	}
+
+	var next []runConfig
+	for _, config := range configs {
+		for _, n := range sweep.Values {
+			c := config.withLabel("size", strconv.Itoa(n))
+			c.consts = maps.Clone(c.consts)
+			if c.consts == nil {
+				c.consts = make(map[string]string)
+			}
+			c.consts[sweep.Const] = strconv.Itoa(n)
+			next = append(next, c)
+		}
+	}
+
+	return next
+}
+
+// expandSweeps returns a copy of every config for each combination of the
+// given constant values, labelled "const/<name>: <value>".
+func expandSweeps(configs []runConfig, sweeps map[string][]string) []runConfig {
+	names := make([]string, 0, len(sweeps))
+	for name := range sweeps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Build the cartesian product of all constant values.
+	for _, name := range names {
+		var next []runConfig
+		for _, config := range configs {
+			for _, v := range sweeps[name] {
+				c := config.withLabel("const/"+name, v)
+				c.consts = maps.Clone(c.consts)
+				if c.consts == nil {
+					c.consts = make(map[string]string)
+				}
+				c.consts[name] = v
+				next = append(next, c)
+			}
+		}
+		configs = next
+	}
+
+	return configs
+}
+
+// expandToolchains returns a copy of every config for each toolchain,
+// labelled "go: <version>".
+func expandToolchains(configs []runConfig, toolchains []toolchain) []runConfig {
+	if len(toolchains) == 0 {
+		return configs
+	}
+
+	var next []runConfig
+	for _, config := range configs {
+		for _, tc := range toolchains {
+			c := config.withLabel("go", tc.version)
+			c.goBin = tc.bin
+			c.goVersion = tc.version
+			// Never let the go command switch to another toolchain.
+			c.env = append(c.env, "GOTOOLCHAIN=local")
+			next = append(next, c)
+		}
+	}
+
+	return next
+}
+
+// checkVariantName rejects build variant names that are reserved for the
+// baseline build or for PGO builds.
+func checkVariantName(name string) error {
+	if name == parser.BaselineVariant {
+		return fmt.Errorf("variant name %q is reserved for the baseline build", name)
+	}
+	if strings.HasSuffix(name, pgoVariantSuffix) {
+		return fmt.Errorf("variant name %q must not end in %q, which marks PGO builds", name, pgoVariantSuffix)
+	}
+	return nil
+}
+
+// expandVariants returns a copy of every config for the baseline build and
+// for each build variant, labelled "variant: <name>". The baseline is
+// labelled "variant: baseline" (see parser.BaselineVariant), so that its
+// results do not inherit the label of the variant run before it.
+func expandVariants(configs []runConfig, variants map[string]buildVariant) []runConfig {
+	if len(variants) == 0 {
+		return configs
+	}
+
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var next []runConfig
+	for _, config := range configs {
+		next = append(next, config.withLabel("variant", parser.BaselineVariant))
+		for _, name := range names {
+			c := config.withLabel("variant", name)
+			c.env = append(c.env, variants[name].Env...)
+			c.flags = append(c.flags, variants[name].Flags...)
+			next = append(next, c)
+		}
+	}
+
+	return next
+}
+
+// prepareRunConfigs sets the directory of every config. Configs that need a
+// modified go.mod or constants run in a temporary copy of the group with
+// go.mod and the constants rewritten; all others run in path directly.
+// Configs that only differ in their environment, build flags or labels share
+// a copy. The returned cleanup function removes the temporary copies.
+func prepareRunConfigs(logger *slog.Logger, path string, configs []runConfig) (func(), error) {
+	cleanup := func() {}
+	var tmp string
+	copies := make(map[string]string) // Directory of each copy, by copyKey
+	warned := make(map[string]bool)   // Go versions go.mod was lowered to
+
+	for i := range configs {
+		c := &configs[i]
+		if len(c.requires) == 0 && c.goVersion == "" && len(c.consts) == 0 {
+			c.dir = path
+			continue
+		}
+
+		if len(c.requires) > 0 {
+			// The pinned versions are not in vendor/modules.txt, so the
+			// vendored build moduleEnv selects would fail.
+			root, err := moduleRoot(path)
+			if err != nil {
+				return cleanup, err
+			}
+			if _, err := os.Stat(filepath.Join(root, "vendor")); err == nil {
+				return cleanup, fmt.Errorf("cannot pin dependency versions in %s: the module is vendored, remove the vendor directory or the dependency versions from --dep and _run.yml", root)
+			}
+
+			// go.sum may need entries for the pinned versions, which are
+			// taken from the module cache.
+			c.env = append(c.env, "GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" -mod=mod"))
+		}
+
+		key := c.copyKey()
+		if dir, ok := copies[key]; ok {
+			c.dir = dir
+			continue
+		}
+
+		root, err := moduleRoot(path)
+		if err != nil {
+			return cleanup, err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return cleanup, err
+		}
+
+		if tmp == "" {
+			tmp, err = os.MkdirTemp("", "gobench-run-")
+			if err != nil {
+				return cleanup, fmt.Errorf("failed to create temporary directory: %w", err)
+			}
+			cleanup = func() { os.RemoveAll(tmp) }
+		}
+
+		copyDir := filepath.Join(tmp, fmt.Sprint(len(copies)))
+		if err := copyModule(root, rel, copyDir); err != nil {
+			return cleanup, fmt.Errorf("failed to copy module: %w", err)
+		}
+
+		goModPath := filepath.Join(root, "go.mod")
+		goMod, err := os.ReadFile(goModPath)
+		if err != nil {
+			return cleanup, fmt.Errorf("failed to read go.mod: %w", err)
+		}
+
+		rewritten, lowered, err := rewriteGoMod(goModPath, goMod, root, c.requires, c.goVersion)
+		if err != nil {
+			return cleanup, err
+		}
+		if lowered != "" && !warned[c.goVersion] {
+			warned[c.goVersion] = true
+			logger.Warn("lowering the go version of go.mod to run with an older toolchain, language semantics such as loop variable scoping may differ",
+				"path", path, "go.mod", lowered, "toolchain", c.goVersion)
+		}
+		if err := os.WriteFile(filepath.Join(copyDir, "go.mod"), rewritten, 0644); err != nil {
+			return cleanup, fmt.Errorf("failed to write go.mod: %w", err)
+		}
+
+		c.dir = filepath.Join(copyDir, rel)
+		if err := rewriteConsts(c.dir, c.consts); err != nil {
+			return cleanup, err
+		}
+		copies[key] = c.dir
+	}
+
+	return cleanup, nil
+}
+
+// copyKey identifies the copy of a group a config runs in: configs with the
+// same module versions, Go version and constants share one.
+func (c runConfig) copyKey() string {
+	return labelsKey(c.requires) + "\x00" + c.goVersion + "\x00" + labelsKey(c.consts)
+}
+
+// copyModule copies the parts of the module at root that the group at rel
+// (relative to root) is built from to dst: the whole module if the group is
+// its root, otherwise only the group, go.mod, go.sum, the shared helpers in
+// internal and the vendor directory.
+func copyModule(root, rel, dst string) error {
+	if rel == "." {
+		return os.CopyFS(dst, os.DirFS(root))
+	}
+
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+	for _, name := range []string{rel, "go.mod", "go.sum", utils.InternalDir, "vendor"} {
+		src := filepath.Join(root, name)
+		info, err := os.Stat(src)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			err = os.CopyFS(filepath.Join(dst, name), os.DirFS(src))
+		} else {
+			err = copyFile(src, filepath.Join(dst, name))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moduleRoot returns the absolute path of the nearest directory at or above
+// path that contains a go.mod.
+func moduleRoot(path string) (string, error) {
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no go.mod found for %s", path)
+		}
+		dir = parent
+	}
+}
+
+// rewriteGoMod pins the given module versions in a go.mod located in dir and
+// lowers its go version to goVersion if that toolchain is older, returning
+// the original go version if it was lowered. Existing replacements of pinned
+// modules are dropped, and relative local replacements are made absolute so
+// the file can be used from another directory.
+func rewriteGoMod(name string, data []byte, dir string, requires map[string]string, goVersion string) ([]byte, string, error) {
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+
+	for _, r := range slices.Clone(f.Replace) {
+		if r.New.Version == "" && !filepath.IsAbs(r.New.Path) {
+			if err := f.AddReplace(r.Old.Path, r.Old.Version, filepath.Join(dir, r.New.Path), ""); err != nil {
+				return nil, "", err
+			}
+		}
+	}
+
+	for module, v := range requires {
+		for _, r := range slices.Clone(f.Replace) {
+			if r.Old.Path == module {
+				if err := f.DropReplace(r.Old.Path, r.Old.Version); err != nil {
+					return nil, "", err
+				}
+			}
+		}
+		if err := f.AddRequire(module, v); err != nil {
+			return nil, "", err
+		}
+	}
+
+	var lowered string
+	if version.IsValid(goVersion) && f.Go != nil && version.Compare(goVersion, "go"+f.Go.Version) < 0 {
+		lowered = f.Go.Version
+		if err := f.AddGoStmt(strings.TrimPrefix(version.Lang(goVersion), "go")); err != nil {
+			return nil, "", err
+		}
+		f.DropToolchainStmt()
+	}
+
+	f.Cleanup()
+	out, err := f.Format()
+	return out, lowered, err
+}
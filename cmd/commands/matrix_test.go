@@ -0,0 +1,248 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestRewriteGoMod(t *testing.T) {
+	gomod := `module benchmarks/json
+
+go 1.25.5
+
+toolchain go1.25.5
+
+require github.com/goccy/go-json v0.10.5
+
+replace github.com/goccy/go-json => ../forks/go-json
+
+replace example.com/local => ./local
+`
+
+	got, lowered, err := rewriteGoMod("go.mod", []byte(gomod), "/src/benchmarks/json", map[string]string{
+		"github.com/goccy/go-json": "v0.10.2",
+	}, "go1.22.5")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lowered != "1.25.5" {
+		t.Errorf("expected the original go version 1.25.5 to be reported, got %q", lowered)
+	}
+	out := string(got)
+
+	if !strings.Contains(out, "require github.com/goccy/go-json v0.10.2") {
+		t.Error("expected pinned version in go.mod")
+	}
+	if strings.Contains(out, "forks/go-json") {
+		t.Error("expected replacement of pinned module to be dropped")
+	}
+	if !strings.Contains(out, "example.com/local => /src/benchmarks/json/local") {
+		t.Error("expected relative replacement to be made absolute")
+	}
+	if !strings.Contains(out, "go 1.22\n") {
+		t.Error("expected go version to be lowered to the toolchain")
+	}
+	if strings.Contains(out, "toolchain") {
+		t.Error("expected toolchain line to be dropped")
+	}
+
+	t.Logf("output:\n%s", out)
+}
+
+func TestExpandMatrix(t *testing.T) {
+	configs := expandDependencies([]runConfig{{}}, map[string][]string{
+		"example.com/a": {"v1.0.0", "v1.1.0"},
+		"example.com/b": {"v2.0.0"},
+	})
+	configs = expandToolchains(configs, []toolchain{
+		{bin: "/sdk/go1.22.5/bin/go", version: "go1.22.5"},
+		{bin: "/sdk/go1.24.0/bin/go", version: "go1.24.0"},
+	})
+
+	if len(configs) != 4 {
+		t.Fatalf("expected 4 configs, got %d", len(configs))
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range configs {
+		key := string(labelLines(c.labels))
+		if seen[key] {
+			t.Errorf("duplicate config %q", key)
+		}
+		seen[key] = true
+
+		if c.requires["example.com/a"] != c.labels["dep/example.com/a"] {
+			t.Errorf("requires and labels disagree: %v vs %v", c.requires, c.labels)
+		}
+		if c.goVersion != c.labels["go"] {
+			t.Errorf("go version and label disagree: %s vs %s", c.goVersion, c.labels["go"])
+		}
+	}
+
+	want := parser.LabelPrefix + "dep/example.com/a: v1.0.0\n" +
+		parser.LabelPrefix + "dep/example.com/b: v2.0.0\n" +
+		parser.LabelPrefix + "go: go1.22.5\n"
+	if !seen[want] {
+		t.Errorf("expected config with labels %q", want)
+	}
+}
+
+func TestExpandSweeps(t *testing.T) {
+	configs := expandSweeps([]runConfig{{}}, map[string][]string{
+		"mapSize": {"10", "100"},
+		"prefix":  {"a", "b", "c"},
+	})
+
+	if len(configs) != 6 {
+		t.Fatalf("expected 6 configs, got %d", len(configs))
+	}
+	for _, c := range configs {
+		if c.consts["mapSize"] != c.labels["const/mapSize"] || c.consts["prefix"] != c.labels["const/prefix"] {
+			t.Errorf("consts and labels disagree: %v vs %v", c.consts, c.labels)
+		}
+	}
+	if configs[0].consts["prefix"] == configs[1].consts["prefix"] && configs[0].consts["mapSize"] == configs[1].consts["mapSize"] {
+		t.Error("configs share their constants")
+	}
+}
+
+func TestParseVariantFlags(t *testing.T) {
+	variants, err := parseVariantFlags([]string{
+		"v3=GOAMD64=v3",
+		"nobce=-gcflags=all=-B GOEXPERIMENT=nocoverageredesign",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := variants["v3"].Env; len(got) != 1 || got[0] != "GOAMD64=v3" {
+		t.Errorf("unexpected env of v3: %v", got)
+	}
+	if got := variants["nobce"].Flags; len(got) != 1 || got[0] != "-gcflags=all=-B" {
+		t.Errorf("unexpected flags of nobce: %v", got)
+	}
+
+	for _, invalid := range []string{"v3", "=GOAMD64=v3", "v3=", "v3=GOAMD64"} {
+		if _, err := parseVariantFlags([]string{invalid}); err == nil {
+			t.Errorf("expected error for %q", invalid)
+		}
+	}
+
+	configs := expandVariants([]runConfig{{}}, variants)
+	if len(configs) != 3 {
+		t.Fatalf("expected baseline and 2 variants, got %d", len(configs))
+	}
+	if len(configs[0].labels) != 1 || configs[0].labels["variant"] != parser.BaselineVariant {
+		t.Errorf("expected baseline label, got %v", configs[0].labels)
+	}
+	for _, reserved := range []string{parser.BaselineVariant, "v3+pgo"} {
+		if _, err := parseVariantFlags([]string{reserved + "=-gcflags=all=-B"}); err == nil {
+			t.Errorf("expected error for a variant named %q", reserved)
+		}
+	}
+
+	cmd := configs[1].goCommand("test", "-bench", ".")
+	if got := strings.Join(cmd.Args[1:], " "); got != "test -gcflags=all=-B -bench ." {
+		t.Errorf("expected build flags after the subcommand, got %q", got)
+	}
+}
+
+func TestParseSizeFlag(t *testing.T) {
+	sweep, err := parseSizeFlag("mapSize=10,100")
+	if err != nil || sweep.Const != "mapSize" || len(sweep.Values) != 2 {
+		t.Errorf("unexpected size sweep %+v (%v)", sweep, err)
+	}
+	if _, err := parseSizeFlag("mapSize=ten"); err == nil {
+		t.Error("expected error for non-numeric size")
+	}
+}
+
+func TestPrepareRunConfigs(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module benchmarks\n\ngo 1.25\n",
+		"internal/random/r.go":    "package random\n",
+		"other/other_test.go":     "package other\n",
+		"counter/a-consts.go":     "package counter\n\nconst size = 10\n",
+		"counter/counter_test.go": "package counter\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	group := filepath.Join(root, "counter")
+	configs := expandVariants(expandSweeps([]runConfig{{goVersion: "go1.22.0"}}, map[string][]string{"size": {"1", "2"}}), map[string]buildVariant{"v3": {Env: []string{"GOAMD64=v3"}}})
+	configs = append(configs, runConfig{})
+	cleanup, err := prepareRunConfigs(logger.New(false), group, configs)
+	defer cleanup()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Baseline and variant of a constant value share a copy.
+	if configs[0].dir != configs[1].dir || configs[0].dir == configs[2].dir || configs[0].dir == group {
+		t.Errorf("unexpected directories %q, %q, %q", configs[0].dir, configs[1].dir, configs[2].dir)
+	}
+	if configs[4].dir != group {
+		t.Errorf("expected an unmodified config to run in the group, got %q", configs[4].dir)
+	}
+
+	copyRoot := filepath.Dir(configs[0].dir)
+	if _, err := os.Stat(filepath.Join(copyRoot, "internal", "random", "r.go")); err != nil {
+		t.Errorf("expected shared helpers to be copied: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(copyRoot, "other")); err == nil {
+		t.Error("expected other groups not to be copied")
+	}
+	goMod, err := os.ReadFile(filepath.Join(copyRoot, "go.mod"))
+	if err != nil || !strings.Contains(string(goMod), "go 1.22\n") {
+		t.Errorf("expected go.mod lowered to go 1.22, got %q (%v)", goMod, err)
+	}
+	consts, err := os.ReadFile(filepath.Join(configs[2].dir, "a-consts.go"))
+	if err != nil || !strings.Contains(string(consts), "const size = 2") {
+		t.Errorf("expected rewritten constant, got %q (%v)", consts, err)
+	}
+}
+
+func TestPrepareRunConfigs_vendored(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"go.mod":                  "module benchmarks\n\ngo 1.25\n",
+		"vendor/modules.txt":      "",
+		"counter/counter_test.go": "package counter\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(root, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	group := filepath.Join(root, "counter")
+	configs := []runConfig{{requires: map[string]string{"github.com/cespare/xxhash/v2": "v2.3.0"}}}
+	cleanup, err := prepareRunConfigs(logger.New(false), group, configs)
+	defer cleanup()
+	if err == nil || !strings.Contains(err.Error(), "vendored") {
+		t.Errorf("expected pinned versions to be rejected for a vendored module, got %v", err)
+	}
+
+	// Other toolchains can still be run against a vendored module.
+	configs = []runConfig{{goVersion: "go1.25.0"}}
+	cleanup, err = prepareRunConfigs(logger.New(false), group, configs)
+	defer cleanup()
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package commands
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+	"github.com/marvinjwendt/gobench/cmd/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge <benchmarks directory>...",
+	Short: "Merge results recorded on other machines into the local benchmark groups",
+	Long: `Merge copies the results of every group in the given benchmarks directories
+(for example checkouts on other machines) into the same group of the local
+benchmarks directory, as _bench.<machine>.out and _run.<machine>.json next to
+the group's own _bench.out. <machine> is a fingerprint of the OS, architecture
+and CPU model the results were recorded on, so results of the same kind of
+machine replace each other while those of different machines are kept side by
+side. The generate command includes them as per-machine results.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		debug, _ := cmd.Flags().GetBool("debug")
+		logger := logger.New(debug)
+
+		basePath := cmd.Flag("benchmarks").Value.String()
+		if _, err := os.Stat(basePath); os.IsNotExist(err) {
+			return fmt.Errorf("benchmarks directory does not exist: %s", basePath)
+		}
+
+		merged := 0
+		for _, source := range args {
+			err := utils.WalkOverBenchmarks(source, func(path string) error {
+				rel, err := filepath.Rel(source, path)
+				if err != nil {
+					return fmt.Errorf("failed to get relative group path: %w", err)
+				}
+
+				dest := filepath.Join(basePath, rel)
+				if _, err := os.Stat(dest); os.IsNotExist(err) {
+					logger.Warn("skipping group missing in the benchmarks directory", "group", filepath.ToSlash(rel))
+					return nil
+				}
+
+				machines, err := mergeGroup(logger, path, dest)
+				if err != nil {
+					return fmt.Errorf("failed to merge %s: %w", filepath.ToSlash(rel), err)
+				}
+				for _, machine := range machines {
+					logger.Info("merged", "group", filepath.ToSlash(rel), "machine", machine)
+				}
+				merged += len(machines)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		logger.Info("done", "merged", merged)
+		return nil
+	},
+}
+
+// mergeGroup copies the results of the group at src, its own as well as
+// those merged into it, into the group at dest. Results recorded on the
+// machine dest's own results were recorded on are skipped. It returns the
+// fingerprints of the merged machines.
+func mergeGroup(logger *slog.Logger, src, dest string) ([]string, error) {
+	local := ""
+	if _, err := os.Stat(filepath.Join(dest, "_bench.out")); err == nil {
+		local, err = parser.ReadMachineFingerprint(filepath.Join(dest, "_bench.out"))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Results to merge by machine: the bench output file and its run record
+	type machineFiles struct{ bench, run string }
+	files := make(map[string]machineFiles)
+
+	if _, err := os.Stat(filepath.Join(src, "_bench.out")); err == nil {
+		fingerprint, err := parser.ReadMachineFingerprint(filepath.Join(src, "_bench.out"))
+		if err != nil {
+			return nil, err
+		}
+		files[fingerprint] = machineFiles{filepath.Join(src, "_bench.out"), filepath.Join(src, runRecordFile)}
+	}
+
+	merged, err := filepath.Glob(filepath.Join(src, parser.MachineBenchFile("*")))
+	if err != nil {
+		return nil, err
+	}
+	for _, bench := range merged {
+		fingerprint, err := parser.ReadMachineFingerprint(bench)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := files[fingerprint]; !ok {
+			files[fingerprint] = machineFiles{bench, filepath.Join(src, machineRunRecordFile(fingerprint))}
+		}
+	}
+
+	var machines []string
+	for fingerprint := range files {
+		if fingerprint == local {
+			logger.Warn("skipping results recorded on this machine", "group", dest, "machine", fingerprint)
+			continue
+		}
+		machines = append(machines, fingerprint)
+	}
+	sort.Strings(machines)
+
+	for _, fingerprint := range machines {
+		f := files[fingerprint]
+		if err := copyFile(f.bench, filepath.Join(dest, parser.MachineBenchFile(fingerprint))); err != nil {
+			return nil, err
+		}
+
+		runDest := filepath.Join(dest, machineRunRecordFile(fingerprint))
+		err := copyFile(f.run, runDest)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Drop the run record of earlier results of the machine
+			err = os.Remove(runDest)
+			if errors.Is(err, fs.ErrNotExist) {
+				err = nil
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return machines, nil
+}
+
+// copyFile copies the file at src to dst, replacing it if it exists.
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
+
+// winnerKey identifies the results of one behavior that the implementations
+// of a group are compared on.
+type winnerKey struct {
+	Variation string
+	CPUCount  int
+	GoVersion string
+	Variant   string
+	Labels    string // Canonical form of the labels
+}
+
+// winnerConsistency compares the fastest implementation of every behavior
+// across the local and the merged machines of a group. Behaviors measured on
+// fewer than two machines are left out.
+func winnerConsistency(group parser.BenchmarkGroup) []parser.WinnerConsistency {
+	if len(group.Machines) == 0 {
+		return nil
+	}
+
+	winners := make(map[winnerKey]map[string]string)
+	var all []parser.Variation
+	record := func(machine string, benchmarks map[string][]parser.Variation) {
+		for key, winner := range fastest(benchmarks) {
+			if winners[key] == nil {
+				winners[key] = make(map[string]string)
+			}
+			winners[key][machine] = winner
+		}
+	}
+
+	local := make(map[string][]parser.Variation, len(group.Benchmarks))
+	for _, bench := range group.Benchmarks {
+		local[bench.Name] = bench.Variations
+		all = append(all, bench.Variations...)
+	}
+	record(group.Machine, local)
+	for _, machine := range group.Machines {
+		benchmarks := make(map[string][]parser.Variation, len(machine.Benchmarks))
+		for _, bench := range machine.Benchmarks {
+			benchmarks[bench.Name] = bench.Variations
+			all = append(all, bench.Variations...)
+		}
+		record(machine.Machine, benchmarks)
+	}
+
+	var result []parser.WinnerConsistency
+	for _, vs := range groupSeries(all, winnerKeyOf, compareWinnerKeys) {
+		key := vs.key
+		byMachine := winners[key]
+		if len(byMachine) < 2 {
+			continue
+		}
+
+		counts := make(map[string]int)
+		for _, winner := range byMachine {
+			counts[winner]++
+		}
+		winner := ""
+		for name, count := range counts {
+			if count > counts[winner] || (count == counts[winner] && name < winner) {
+				winner = name
+			}
+		}
+
+		result = append(result, parser.WinnerConsistency{
+			Variation:  key.Variation,
+			CPUCount:   key.CPUCount,
+			GoVersion:  key.GoVersion,
+			Variant:    key.Variant,
+			Labels:     vs.labels,
+			Winners:    byMachine,
+			Winner:     winner,
+			Agreement:  float64(counts[winner]) / float64(len(byMachine)),
+			Consistent: counts[winner] == len(byMachine),
+		})
+	}
+
+	return result
+}
+
+// winnerKeyOf returns the key of the behavior v measures.
+func winnerKeyOf(v parser.Variation) winnerKey {
+	return winnerKey{Variation: v.Name, CPUCount: v.CPUCount, GoVersion: v.GoVersion, Variant: v.Variant, Labels: labelsKey(v.Labels)}
+}
+
+func compareWinnerKeys(a, b winnerKey) int {
+	return cmp.Or(
+		cmp.Compare(a.Variation, b.Variation),
+		cmp.Compare(a.CPUCount, b.CPUCount),
+		cmp.Compare(a.GoVersion, b.GoVersion),
+		cmp.Compare(a.Variant, b.Variant),
+		cmp.Compare(a.Labels, b.Labels),
+	)
+}
+
+// fastest returns the implementation with the lowest median ns/op of every
+// behavior measured by more than one implementation on a machine.
+func fastest(benchmarks map[string][]parser.Variation) map[winnerKey]string {
+	nsPerOp := make(map[winnerKey]map[string][]parser.Variation)
+	for name, variations := range benchmarks {
+		for _, vs := range groupSeries(variations, winnerKeyOf, compareWinnerKeys) {
+			if nsPerOp[vs.key] == nil {
+				nsPerOp[vs.key] = make(map[string][]parser.Variation)
+			}
+			nsPerOp[vs.key][name] = vs.variations
+		}
+	}
+
+	result := make(map[winnerKey]string, len(nsPerOp))
+	for key, byName := range nsPerOp {
+		if len(byName) < 2 {
+			continue
+		}
+
+		names := make([]string, 0, len(byName))
+		for name := range byName {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		best, bestNsPerOp := "", 0.0
+		for _, name := range names {
+			ns := medianFloat(byName[name], func(v parser.Variation) float64 { return v.NsPerOp })
+			if best == "" || ns < bestNsPerOp {
+				best, bestNsPerOp = name, ns
+			}
+		}
+		result[key] = best
+	}
+
+	return result
+}
+
+func init() {
+	mergeCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
+
+	rootCmd.AddCommand(mergeCmd)
+}
@@ -0,0 +1,98 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestWinnerConsistency(t *testing.T) {
+	variation := func(name string, nsPerOp float64) parser.Variation {
+		var v parser.Variation
+		v.Name = name
+		v.NsPerOp = nsPerOp
+		return v
+	}
+	benchmarks := func(array, slice float64) []parser.Benchmark {
+		return []parser.Benchmark{
+			{Name: "Array", Variations: []parser.Variation{variation("Read", array), variation("Write", 10)}},
+			{Name: "Slice", Variations: []parser.Variation{variation("Read", slice), variation("Write", 20)}},
+		}
+	}
+	machine := func(fingerprint string, array, slice float64) parser.MachineResults {
+		m := parser.MachineResults{Machine: fingerprint}
+		for _, bench := range benchmarks(array, slice) {
+			m.Benchmarks = append(m.Benchmarks, parser.MachineBenchmark{Name: bench.Name, Variations: bench.Variations})
+		}
+		return m
+	}
+
+	group := parser.BenchmarkGroup{Machine: "amd", Benchmarks: benchmarks(1, 2)}
+	if result := winnerConsistency(group); result != nil {
+		t.Errorf("expected no comparison for a single machine, got %v", result)
+	}
+
+	group.Machines = []parser.MachineResults{machine("arm", 3, 2), machine("intel", 1, 5)}
+	result := winnerConsistency(group)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 comparisons, got %d", len(result))
+	}
+
+	read := result[0]
+	if read.Variation != "Read" || read.Winner != "Array" || read.Consistent {
+		t.Errorf("expected Array to win Read inconsistently, got %+v", read)
+	}
+	if read.Winners["arm"] != "Slice" || read.Agreement != 2.0/3 {
+		t.Errorf("unexpected winners %v with agreement %v", read.Winners, read.Agreement)
+	}
+	if write := result[1]; write.Winner != "Array" || !write.Consistent || write.Agreement != 1 {
+		t.Errorf("expected Array to win Write on every machine, got %+v", write)
+	}
+}
+
+func TestMergeGroup(t *testing.T) {
+	writeBench := func(dir, cpu string) {
+		t.Helper()
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		out := "goos: linux\ngoarch: amd64\ncpu: " + cpu + "\nBenchmarkArray/Read-8   1000   10 ns/op\n"
+		if err := os.WriteFile(filepath.Join(dir, "_bench.out"), []byte(out), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := t.TempDir()
+	src, dest := filepath.Join(root, "src"), filepath.Join(root, "dest")
+	writeBench(src, "Remote CPU")
+	writeBench(dest, "Local CPU")
+	if err := os.WriteFile(filepath.Join(src, runRecordFile), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := parser.MachineFingerprint(parser.SystemInfo{GoOS: "linux", GoArch: "amd64", CPU: "Remote CPU"})
+	machines, err := mergeGroup(logger.New(false), src, dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 1 || machines[0] != remote {
+		t.Fatalf("expected machine %s to be merged, got %v", remote, machines)
+	}
+	for _, file := range []string{parser.MachineBenchFile(remote), machineRunRecordFile(remote)} {
+		if _, err := os.Stat(filepath.Join(dest, file)); err != nil {
+			t.Errorf("expected %s to be merged: %v", file, err)
+		}
+	}
+
+	// Merging back skips the results of the local machine.
+	machines, err = mergeGroup(logger.New(false), dest, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(machines) != 1 || machines[0] == remote {
+		t.Errorf("expected only the other machine to be merged back, got %v", machines)
+	}
+}
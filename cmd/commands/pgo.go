@@ -0,0 +1,125 @@
+package commands
+
+import (
+	"fmt"
+	"go/version"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/google/pprof/profile"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+const (
+	// pgoProfileRuns is the number of profiled runs merged into the PGO
+	// profile of a config. Merging several runs smooths out sampling noise.
+	pgoProfileRuns = 3
+	// pgoBenchtime is the iteration count of each profiled run.
+	pgoBenchtime = "10000x"
+	// pgoVariantSuffix marks a build variant rebuilt with PGO.
+	pgoVariantSuffix = "+pgo"
+	// pgoMinGoVersion is the first Go release supporting -pgo.
+	pgoMinGoVersion = "go1.21"
+)
+
+// expandPGO collects a CPU profile of every config by running its benchmarks
+// with -cpuprofile, merges the profiles of each config into a single PGO
+// profile in dir and returns the configs followed by a copy of each config
+// rebuilt with its profile. The copies are labelled with the variant "pgo",
+// or "<variant>+pgo" for configs that already are a build variant; configs
+// without a variant are labelled as the baseline (see expandVariants).
+// Configs of toolchains older than pgoMinGoVersion are not rebuilt.
+func expandPGO(logger *slog.Logger, configs []runConfig, cpu string, dir string) ([]runConfig, error) {
+	next := pgoBaselines(configs)
+	warned := make(map[string]bool)
+	for i, config := range next[:len(configs)] {
+		if version.IsValid(config.goVersion) && version.Compare(config.goVersion, pgoMinGoVersion) < 0 {
+			if !warned[config.goVersion] {
+				warned[config.goVersion] = true
+				logger.Warn("skipping pgo for a toolchain without pgo support", "toolchain", config.goVersion, "minimum", pgoMinGoVersion, "path", config.dir)
+			}
+			continue
+		}
+
+		var profiles []string
+		for run := range pgoProfileRuns {
+			name := filepath.Join(dir, fmt.Sprintf("%d-%d.pprof", i, run))
+			cmd := config.goCommand("test", "-run", "^$", "-bench", ".", "-benchtime", pgoBenchtime, "-cpu", cpu,
+				"-cpuprofile", name, "-o", filepath.Join(dir, fmt.Sprintf("%d.test", i)))
+			logger.Debug("collecting cpu profile", "command", cmd.String(), "path", config.dir, "labels", config.labels)
+			if out, err := cmd.CombinedOutput(); err != nil {
+				logger.Error("failed to collect cpu profile", "path", config.dir, "output", string(out))
+				return nil, fmt.Errorf("failed to collect cpu profile: %w", err)
+			}
+			profiles = append(profiles, name)
+		}
+
+		pgoFile := filepath.Join(dir, fmt.Sprintf("%d.pgo", i))
+		if err := mergeProfiles(profiles, pgoFile); err != nil {
+			return nil, err
+		}
+
+		next = append(next, pgoVariant(config, pgoFile))
+	}
+
+	return next, nil
+}
+
+// pgoBaselines returns a copy of configs with every config that is not a
+// build variant labelled as the baseline.
+func pgoBaselines(configs []runConfig) []runConfig {
+	baselines := make([]runConfig, 0, 2*len(configs))
+	for _, config := range configs {
+		if _, ok := config.labels["variant"]; !ok {
+			config = config.withLabel("variant", parser.BaselineVariant)
+		}
+		baselines = append(baselines, config)
+	}
+	return baselines
+}
+
+// pgoVariant returns a copy of config rebuilt with the PGO profile pgoFile.
+func pgoVariant(config runConfig, pgoFile string) runConfig {
+	variant := "pgo"
+	if v := config.labels["variant"]; v != parser.BaselineVariant {
+		variant = v + pgoVariantSuffix
+	}
+	c := config.withLabel("variant", variant)
+	c.flags = append(c.flags, "-pgo="+pgoFile)
+	return c
+}
+
+// mergeProfiles merges the pprof profiles in names into a single profile
+// written to out.
+func mergeProfiles(names []string, out string) error {
+	var profiles []*profile.Profile
+	for _, name := range names {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open profile: %w", err)
+		}
+		p, err := profile.Parse(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to parse profile %s: %w", name, err)
+		}
+		profiles = append(profiles, p)
+	}
+
+	merged, err := profile.Merge(profiles)
+	if err != nil {
+		return fmt.Errorf("failed to merge profiles: %w", err)
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create profile: %w", err)
+	}
+	defer f.Close()
+
+	if err := merged.Write(f); err != nil {
+		return fmt.Errorf("failed to write profile: %w", err)
+	}
+	return f.Close()
+}
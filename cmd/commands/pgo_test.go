@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/pprof/profile"
+	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestPGOResults(t *testing.T) {
+	configs := pgoBaselines(expandVariants([]runConfig{{}}, map[string]buildVariant{"v3": {Env: []string{"GOAMD64=v3"}}}))
+	for i := range len(configs) {
+		configs = append(configs, pgoVariant(configs[i], fmt.Sprintf("%d.pgo", i)))
+	}
+	nsPerOp := map[string]int{parser.BaselineVariant: 100, "v3": 80, "pgo": 60, "v3+pgo": 40}
+
+	// Output of a group run twice with --variant and --pgo: every build in
+	// each run.
+	var out strings.Builder
+	for range 2 {
+		for _, config := range configs {
+			out.Write(labelLines(config.labels))
+			fmt.Fprintf(&out, "goos: linux\ngoarch: amd64\ncpu: CPU\nBenchmarkGroup/Run-1   1000   %d ns/op\n", nsPerOp[config.labels["variant"]])
+		}
+	}
+
+	dir := t.TempDir()
+	writeGroup(t, filepath.Join(dir, "group"), "package group\n", "")
+	if err := os.WriteFile(filepath.Join(dir, "group", "_bench.out"), []byte(out.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := parser.ProcessBenchmarkGroups(logger.New(false), dir, parser.ProcessOptions{})
+	if err != nil || len(groups) != 1 {
+		t.Fatalf("expected a single group, got %d (%v)", len(groups), err)
+	}
+	medianVariations(&groups[0])
+
+	bench := groups[0].Benchmarks[0]
+	if len(bench.Variations) != 4 {
+		t.Fatalf("expected a variation of every build, got %+v", bench.Variations)
+	}
+	for _, v := range bench.Variations {
+		if v.Labels != nil {
+			t.Errorf("expected no labels, got %v", v.Labels)
+		}
+		if want := nsPerOp[cmp.Or(v.Variant, parser.BaselineVariant)]; v.NsPerOp != float64(want) {
+			t.Errorf("variant %q: expected %v ns/op, got %v", v.Variant, want, v.NsPerOp)
+		}
+	}
+
+	// PGO builds are compared with the same build without PGO.
+	want := map[string]struct {
+		baseline string
+		change   float64
+	}{"pgo": {"", -0.4}, "v3": {"", -0.2}, "v3+pgo": {"v3", -0.5}}
+	results := variantResults(bench)
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), results)
+	}
+	for _, r := range results {
+		if w := want[r.Variant]; r.Baseline != w.baseline || r.Change != w.change {
+			t.Errorf("%s: expected a change of %v compared with %q, got %v compared with %q", r.Variant, w.change, w.baseline, r.Change, r.Baseline)
+		}
+	}
+}
+
+func TestExpandPGO_oldToolchain(t *testing.T) {
+	configs, err := expandPGO(logger.New(false), []runConfig{{goVersion: "go1.20.14"}}, "1", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 1 || configs[0].labels["variant"] != parser.BaselineVariant {
+		t.Errorf("expected only the baseline for a toolchain without pgo support, got %+v", configs)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	dir := t.TempDir()
+
+	var names []string
+	for i, samples := range []int64{3, 4} {
+		fn := &profile.Function{ID: 1, Name: "main.run"}
+		loc := &profile.Location{ID: 1, Line: []profile.Line{{Function: fn}}}
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}},
+			Sample:     []*profile.Sample{{Location: []*profile.Location{loc}, Value: []int64{samples}}},
+			Location:   []*profile.Location{loc},
+			Function:   []*profile.Function{fn},
+		}
+
+		name := filepath.Join(dir, fmt.Sprintf("%d.pprof", i))
+		f, err := os.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.Write(f); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		names = append(names, name)
+	}
+
+	out := filepath.Join(dir, "default.pgo")
+	if err := mergeProfiles(names, out); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	f, err := os.Open(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	merged, err := profile.Parse(f)
+	if err != nil {
+		t.Fatalf("failed to parse merged profile: %v", err)
+	}
+	if len(merged.Sample) != 1 || merged.Sample[0].Value[0] != 7 {
+		t.Errorf("expected a single sample with value 7, got %v", merged.Sample)
+	}
+}
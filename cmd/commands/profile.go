@@ -0,0 +1,303 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// profilesDir is the directory inside a group that profiles are stored in,
+// as <profilesDir>/<kind>/<impl>_<behavior>.pprof. Each kind has its own
+// directory, as every profile kind is a separate file.
+const profilesDir = "_profiles"
+
+// profileLabelsFile is the file in profilesDir holding the labels of the run
+// matrix cell the profiles were collected with.
+const profileLabelsFile = "labels.json"
+
+// profileFlags maps the profile kinds accepted by --profile to go test flags.
+var profileFlags = map[string]string{
+	"cpu":   "-cpuprofile",
+	"mem":   "-memprofile",
+	"block": "-blockprofile",
+	"mutex": "-mutexprofile",
+}
+
+// parseProfileKinds validates --profile values.
+func parseProfileKinds(kinds []string) ([]string, error) {
+	for _, kind := range kinds {
+		if _, ok := profileFlags[kind]; !ok {
+			return nil, fmt.Errorf("unknown profile kind %q, expected one of cpu, mem, block, mutex", kind)
+		}
+	}
+	return kinds, nil
+}
+
+// runProfiles runs every benchmark function of the group at path in
+// isolation with the given profiles enabled and stores the profiles in the
+// group's profilesDir, replacing previously collected ones. Only config is
+// profiled; its labels are stored next to the profiles, so summaries can tell
+// which cell of the run matrix they cover.
+func runProfiles(logger *slog.Logger, config runConfig, path string, kinds []string) error {
+	out, err := config.goCommand("test", "-list", "^Benchmark").Output()
+	if err != nil {
+		return fmt.Errorf("failed to list benchmarks: %w", err)
+	}
+
+	benchmarks := parseBenchmarkList(out)
+
+	dir, err := filepath.Abs(filepath.Join(path, profilesDir))
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove old profiles: %w", err)
+	}
+	for _, kind := range kinds {
+		if err := os.MkdirAll(filepath.Join(dir, kind), 0755); err != nil {
+			return fmt.Errorf("failed to create profile directory: %w", err)
+		}
+	}
+	labels, err := json.Marshal(config.labels)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile labels: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, profileLabelsFile), append(labels, '\n'), 0644); err != nil {
+		return err
+	}
+
+	// The test binary is kept by go test when profiling; keep it out of the group.
+	tmp, err := os.MkdirTemp("", "gobench-profile-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+
+	for _, name := range benchmarks {
+		args := []string{"test", "-run", "^$", "-bench", "^" + name + "$", "-benchmem", "-o", filepath.Join(tmp, "bench.test")}
+		for _, kind := range kinds {
+			args = append(args, profileFlags[kind], filepath.Join(dir, kind, strings.TrimPrefix(name, "Benchmark")+".pprof"))
+		}
+
+		cmd := config.goCommand(args...)
+		logger.Debug("collecting profiles", "command", cmd.String(), "benchmark", name)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			logger.Error("failed to collect profiles", "benchmark", name, "output", string(out))
+			return fmt.Errorf("failed to collect profiles of %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// benchmarkProfiles summarises the profiles stored for a benchmark (the
+// implementation name without spaces, e.g. "DirectMethodCall") in the group
+// directory dir, keeping the top functions of each. Every summary carries the
+// labels of the run matrix cell that was profiled.
+func benchmarkProfiles(dir, benchmark string, top int) ([]parser.Profile, error) {
+	kinds, err := os.ReadDir(filepath.Join(dir, profilesDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profiles: %w", err)
+	}
+
+	var labels map[string]string
+	b, err := os.ReadFile(filepath.Join(dir, profilesDir, profileLabelsFile))
+	if err == nil {
+		err = json.Unmarshal(b, &labels)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read profile labels: %w", err)
+	}
+
+	var profiles []parser.Profile
+	for _, kind := range kinds {
+		if !kind.IsDir() {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(dir, profilesDir, kind.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read profiles: %w", err)
+		}
+
+		for _, entry := range entries {
+			base, ok := strings.CutSuffix(entry.Name(), ".pprof")
+			if !ok {
+				continue
+			}
+			impl, behavior, _ := strings.Cut(base, "_")
+			if impl != benchmark {
+				continue
+			}
+
+			name := filepath.Join(dir, profilesDir, kind.Name(), entry.Name())
+			f, err := os.Open(name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open profile: %w", err)
+			}
+			p, err := profile.Parse(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse profile %s: %w", name, err)
+			}
+
+			summary := summarizeProfile(p, kind.Name(), top)
+			summary.Variation = strings.NewReplacer("_", " ", "-", " ").Replace(behavior)
+			summary.Labels = labels
+			summary.File = filepath.ToSlash(filepath.Join(profilesDir, kind.Name(), entry.Name()))
+			svg := filepath.Join(flameGraphsDir, kind.Name(), base+".svg")
+			if _, err := os.Stat(filepath.Join(dir, svg)); err == nil {
+				summary.FlameGraph = filepath.ToSlash(svg)
+			}
+			profiles = append(profiles, summary)
+		}
+	}
+
+	return profiles, nil
+}
+
+// summarizeProfile aggregates the samples of a profile by function and keeps
+// the functions among the top by flat or cumulative value. Memory profiles
+// are summarised by allocated bytes and additionally list allocation sites.
+func summarizeProfile(p *profile.Profile, kind string, top int) parser.Profile {
+	index := sampleTypeIndex(p, kind)
+
+	summary := parser.Profile{Kind: kind}
+	if index < 0 {
+		return summary
+	}
+	summary.SampleType = p.SampleType[index].Type
+	summary.Unit = p.SampleType[index].Unit
+
+	type site struct {
+		function, file string
+		line           int64
+	}
+	functions := make(map[string]*parser.ProfileFunction)
+	sites := make(map[site]*parser.ProfileFunction)
+
+	for _, s := range p.Sample {
+		value := s.Value[index]
+		if value == 0 {
+			continue
+		}
+		summary.Total += value
+
+		seen := make(map[string]bool)
+		for i, loc := range s.Location {
+			// Inlined calls are listed innermost first.
+			for j, line := range loc.Line {
+				if line.Function == nil {
+					continue
+				}
+				name := line.Function.Name
+				f := functions[name]
+				if f == nil {
+					f = &parser.ProfileFunction{Name: name, File: line.Function.Filename}
+					functions[name] = f
+				}
+				if !seen[name] {
+					seen[name] = true
+					f.Cum += value
+				}
+
+				if i == 0 && j == 0 {
+					f.Flat += value
+					if kind == "mem" {
+						key := site{name, line.Function.Filename, line.Line}
+						if sites[key] == nil {
+							sites[key] = &parser.ProfileFunction{Name: name, File: key.file, Line: int(line.Line)}
+						}
+						sites[key].Flat += value
+						sites[key].Cum += value
+					}
+				}
+			}
+		}
+	}
+
+	summary.Functions = topFunctions(functions, top)
+	if kind == "mem" {
+		sitesByKey := make(map[string]*parser.ProfileFunction, len(sites))
+		for key, s := range sites {
+			sitesByKey[fmt.Sprintf("%s:%d", key.file, key.line)] = s
+		}
+		summary.AllocationSites = topFunctions(sitesByKey, top)
+	}
+
+	return summary
+}
+
+// sampleTypeIndex returns the index of the sample type that profiles of kind
+// are evaluated by: allocated bytes for memory profiles, otherwise the
+// profile's default (usually the last) sample type. It returns -1 for
+// profiles without sample types.
+func sampleTypeIndex(p *profile.Profile, kind string) int {
+	sampleType := p.DefaultSampleType
+	if kind == "mem" {
+		sampleType = "alloc_space"
+	}
+
+	index := len(p.SampleType) - 1
+	for i, st := range p.SampleType {
+		if st.Type == sampleType {
+			index = i
+		}
+	}
+	return index
+}
+
+// topFunctions returns the union of the top n functions by flat and by
+// cumulative value, sorted by flat value.
+func topFunctions(functions map[string]*parser.ProfileFunction, n int) []parser.ProfileFunction {
+	all := make([]parser.ProfileFunction, 0, len(functions))
+	for _, f := range functions {
+		all = append(all, *f)
+	}
+
+	byCum := slices.Clone(all)
+	sort.Slice(byCum, func(i, j int) bool {
+		if byCum[i].Cum != byCum[j].Cum {
+			return byCum[i].Cum > byCum[j].Cum
+		}
+		return byCum[i].Name < byCum[j].Name
+	})
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Flat != all[j].Flat {
+			return all[i].Flat > all[j].Flat
+		}
+		if all[i].Cum != all[j].Cum {
+			return all[i].Cum > all[j].Cum
+		}
+		return all[i].Name < all[j].Name
+	})
+
+	keep := make(map[parser.ProfileFunction]bool)
+	for i := 0; i < n && i < len(all); i++ {
+		if all[i].Flat > 0 {
+			keep[all[i]] = true
+		}
+		keep[byCum[i]] = true
+	}
+
+	var result []parser.ProfileFunction
+	for _, f := range all {
+		if keep[f] {
+			result = append(result, f)
+		}
+	}
+	return result
+}
@@ -0,0 +1,88 @@
+package commands
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/pprof/profile"
+)
+
+// cpuTestProfile returns a CPU profile of a benchmark that spends 300ns in
+// sync.(*Mutex).Lock, 100ns in (*MutexCounter).increment and 50ns in the
+// benchmark function itself.
+func cpuTestProfile() *profile.Profile {
+	fn := func(id uint64, name string) *profile.Function {
+		return &profile.Function{ID: id, Name: name, Filename: "counter_test.go"}
+	}
+	bench, increment, lock := fn(1, "BenchmarkMutexCounter_increment"), fn(2, "(*MutexCounter).increment"), fn(3, "sync.(*Mutex).Lock")
+	loc := func(id uint64, f *profile.Function, line int64) *profile.Location {
+		return &profile.Location{ID: id, Line: []profile.Line{{Function: f, Line: line}}}
+	}
+	benchLoc, incrementLoc, lockLoc := loc(1, bench, 20), loc(2, increment, 10), loc(3, lock, 5)
+
+	return &profile.Profile{
+		SampleType: []*profile.ValueType{{Type: "samples", Unit: "count"}, {Type: "cpu", Unit: "nanoseconds"}},
+		Sample: []*profile.Sample{
+			{Location: []*profile.Location{lockLoc, incrementLoc, benchLoc}, Value: []int64{3, 300}},
+			{Location: []*profile.Location{incrementLoc, benchLoc}, Value: []int64{1, 100}},
+			{Location: []*profile.Location{benchLoc}, Value: []int64{1, 50}},
+		},
+		Location: []*profile.Location{benchLoc, incrementLoc, lockLoc},
+		Function: []*profile.Function{bench, increment, lock},
+	}
+}
+
+func TestSummarizeProfile(t *testing.T) {
+	summary := summarizeProfile(cpuTestProfile(), "cpu", 2)
+	if summary.SampleType != "cpu" || summary.Unit != "nanoseconds" || summary.Total != 450 {
+		t.Fatalf("unexpected summary: %+v", summary)
+	}
+
+	// Top 2 by flat are Lock and increment, top 2 by cum are the benchmark and increment.
+	if len(summary.Functions) != 3 {
+		t.Fatalf("expected 3 functions, got %+v", summary.Functions)
+	}
+	first, last := summary.Functions[0], summary.Functions[2]
+	if first.Name != "sync.(*Mutex).Lock" || first.Flat != 300 || first.Cum != 300 {
+		t.Errorf("unexpected first function: %+v", first)
+	}
+	if last.Name != "BenchmarkMutexCounter_increment" || last.Flat != 50 || last.Cum != 450 {
+		t.Errorf("unexpected last function: %+v", last)
+	}
+	if summary.AllocationSites != nil {
+		t.Errorf("expected no allocation sites for cpu profiles")
+	}
+}
+
+func TestBenchmarkProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{path.Join(profilesDir, profileLabelsFile): `{"go":"go1.24.0"}`})
+	if err := os.MkdirAll(filepath.Join(dir, profilesDir, "cpu"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Create(filepath.Join(dir, profilesDir, "cpu", "MutexCounter_increment.pprof"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cpuTestProfile().Write(f); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	profiles, err := benchmarkProfiles(dir, "MutexCounter", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("expected a single profile, got %+v", profiles)
+	}
+	p := profiles[0]
+	if p.Kind != "cpu" || p.Variation != "increment" || p.File != "_profiles/cpu/MutexCounter_increment.pprof" {
+		t.Errorf("unexpected profile %+v", p)
+	}
+	if p.Labels["go"] != "go1.24.0" {
+		t.Errorf("expected the labels of the profiled cell, got %v", p.Labels)
+	}
+}
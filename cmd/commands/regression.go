@@ -0,0 +1,122 @@
+package commands
+
+import (
+	"cmp"
+	"slices"
+	"sort"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// costFits fits the total time of every behavior, CPU count and build
+// against the iteration count N of the benchtime sweep. The slope is the
+// per-op cost and the intercept the fixed overhead of a benchmark run.
+// Fits with an R² below threshold are marked as not linear: the cost of an
+// operation then depends on how many operations ran before it.
+func costFits(bench parser.Benchmark, threshold float64) []parser.CostFit {
+	type seriesKey struct {
+		Variation string
+		CPUCount  int
+		GoVersion string
+		Variant   string
+		Labels    string
+	}
+
+	series := groupSeries(bench.Variations,
+		func(v parser.Variation) seriesKey {
+			return seriesKey{Variation: v.Name, CPUCount: v.CPUCount, GoVersion: v.GoVersion, Variant: v.Variant, Labels: labelsKey(v.Labels)}
+		},
+		func(a, b seriesKey) int {
+			return cmp.Or(cmp.Compare(a.Variation, b.Variation), cmp.Compare(a.CPUCount, b.CPUCount))
+		})
+
+	var fits []parser.CostFit
+	for _, vs := range series {
+		key := vs.key
+		var xs, ys []float64
+		for _, v := range vs.variations {
+			xs = append(xs, float64(v.N))
+			ys = append(ys, v.NsPerOp*float64(v.N))
+		}
+
+		slope, intercept, ok := theilSen(xs, ys)
+		if !ok {
+			continue
+		}
+		r2 := rSquared(xs, ys, slope, intercept)
+
+		fits = append(fits, parser.CostFit{
+			Variation:  key.Variation,
+			CPUCount:   key.CPUCount,
+			GoVersion:  key.GoVersion,
+			Variant:    key.Variant,
+			Labels:     vs.labels,
+			NsPerOp:    slope,
+			OverheadNs: intercept,
+			RSquared:   r2,
+			Points:     len(xs),
+			Linear:     r2 >= threshold,
+		})
+	}
+
+	return fits
+}
+
+// theilSen fits y = slope*x + intercept with the Theil–Sen estimator: the
+// slope is the median of the slopes between all pairs of points, the
+// intercept the median of y - slope*x. Unlike least squares it is not
+// thrown off by a few outliers. ok is false if fewer than two distinct x
+// values are given.
+func theilSen(xs, ys []float64) (slope, intercept float64, ok bool) {
+	var slopes []float64
+	for i := range xs {
+		for j := i + 1; j < len(xs); j++ {
+			if xs[i] != xs[j] {
+				slopes = append(slopes, (ys[j]-ys[i])/(xs[j]-xs[i]))
+			}
+		}
+	}
+	if len(slopes) == 0 {
+		return 0, 0, false
+	}
+	slope = median(slopes)
+
+	intercepts := make([]float64, len(xs))
+	for i := range xs {
+		intercepts[i] = ys[i] - slope*xs[i]
+	}
+	return slope, median(intercepts), true
+}
+
+// rSquared returns the coefficient of determination of a linear fit. It is 1
+// if all points lie on a horizontal line.
+func rSquared(xs, ys []float64, slope, intercept float64) float64 {
+	mean := 0.0
+	for _, y := range ys {
+		mean += y
+	}
+	mean /= float64(len(ys))
+
+	var ssRes, ssTot float64
+	for i := range xs {
+		res := ys[i] - (slope*xs[i] + intercept)
+		ssRes += res * res
+		ssTot += (ys[i] - mean) * (ys[i] - mean)
+	}
+	if ssTot == 0 {
+		return 1
+	}
+	return 1 - ssRes/ssTot
+}
+
+// median returns the median of vals without modifying it.
+func median(vals []float64) float64 {
+	sorted := slices.Clone(vals)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestTheilSen(t *testing.T) {
+	// y = 3x + 100, with one outlier that would skew a least squares fit.
+	xs := []float64{1000, 2000, 3000, 4000, 5000}
+	ys := []float64{3100, 6100, 9100, 40000, 15100}
+
+	slope, intercept, ok := theilSen(xs, ys)
+	if !ok {
+		t.Fatal("expected a fit")
+	}
+	if slope != 3 || intercept != 100 {
+		t.Errorf("expected y = 3x + 100, got y = %fx + %f", slope, intercept)
+	}
+
+	if _, _, ok := theilSen([]float64{1000, 1000}, []float64{1, 2}); ok {
+		t.Error("expected no fit for a single x value")
+	}
+}
+
+func TestCostFits(t *testing.T) {
+	variation := func(name string, n int, nsPerOp float64) parser.Variation {
+		v := parser.Variation{Name: name, CPUCount: 1}
+		v.N = n
+		v.NsPerOp = nsPerOp
+		return v
+	}
+
+	var bench parser.Benchmark
+	for n := 1000; n <= 10000; n += 1000 {
+		// Constant cost of 5ns/op plus 1µs of setup.
+		bench.Variations = append(bench.Variations, variation("constant", n, 5+1000/float64(n)))
+		// The i-th operation costs i ns.
+		bench.Variations = append(bench.Variations, variation("growing", n, float64(n)/2))
+	}
+
+	fits := costFits(bench, 0.95)
+	if len(fits) != 2 {
+		t.Fatalf("expected 2 fits, got %d", len(fits))
+	}
+
+	constant, growing := fits[0], fits[1]
+	if math.Abs(constant.NsPerOp-5) > 1e-9 || math.Abs(constant.OverheadNs-1000) > 1e-6 || !constant.Linear {
+		t.Errorf("unexpected fit of constant cost: %+v", constant)
+	}
+	if growing.Linear || growing.Points != 10 {
+		t.Errorf("expected growing cost to be flagged as not linear: %+v", growing)
+	}
+}
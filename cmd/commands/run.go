@@ -3,6 +3,8 @@ package commands
 import (
 	"fmt"
 	"log/slog"
+	"maps"
+	"math/rand/v2"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -11,8 +13,10 @@ import (
 	"time"
 
 	"github.com/marvinjwendt/gobench/cmd/internal/logger"
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
 	"github.com/marvinjwendt/gobench/cmd/internal/utils"
 	"github.com/spf13/cobra"
+	"golang.org/x/tools/benchmark/parse"
 )
 
 var runCmd = &cobra.Command{
@@ -22,9 +26,65 @@ var runCmd = &cobra.Command{
 		debug, _ := cmd.Flags().GetBool("debug")
 		all, _ := cmd.Flags().GetBool("all")
 		count, _ := cmd.Flags().GetInt("count")
+		adaptive, _ := cmd.Flags().GetBool("adaptive")
+		targetCI, _ := cmd.Flags().GetFloat64("target-ci")
+		maxCount, _ := cmd.Flags().GetInt("max-count")
+		maxTime, _ := cmd.Flags().GetDuration("max-time")
+		interleave, _ := cmd.Flags().GetBool("interleave")
+		seed, _ := cmd.Flags().GetInt64("seed")
+		depFlags, _ := cmd.Flags().GetStringArray("dep")
+		goVersions, _ := cmd.Flags().GetStringSlice("go")
+		variantFlags, _ := cmd.Flags().GetStringArray("variant")
+		pgo, _ := cmd.Flags().GetBool("pgo")
+		profileFlagValues, _ := cmd.Flags().GetStringSlice("profile")
+		sizeFlag, _ := cmd.Flags().GetString("size")
+		sweepFlags, _ := cmd.Flags().GetStringArray("sweep")
+		force, _ := cmd.Flags().GetBool("force")
+		hostRoot, _ := cmd.Flags().GetString("host-root")
+		maxTemp, _ := cmd.Flags().GetFloat64("max-temp")
+		maxLoad, _ := cmd.Flags().GetFloat64("max-load")
+		cooldownTimeout, _ := cmd.Flags().GetDuration("cooldown-timeout")
+		sentinel, _ := cmd.Flags().GetBool("sentinel")
+		sentinelThreshold, _ := cmd.Flags().GetFloat64("sentinel-threshold")
+		discardNoisy, _ := cmd.Flags().GetBool("discard-noisy")
+		bundle, _ := cmd.Flags().GetString("bundle")
+		keyFile, _ := cmd.Flags().GetString("key")
 		logger := logger.New(debug)
 
-		logger.Info("running benchmarks", "count", count)
+		deps, err := parseDependencyFlags(depFlags)
+		if err != nil {
+			return err
+		}
+
+		variants, err := parseVariantFlags(variantFlags)
+		if err != nil {
+			return err
+		}
+
+		profiles, err := parseProfileKinds(profileFlagValues)
+		if err != nil {
+			return err
+		}
+
+		sizes, err := parseSizeFlag(sizeFlag)
+		if err != nil {
+			return err
+		}
+
+		sweeps, err := parseSweepFlags(sweepFlags)
+		if err != nil {
+			return err
+		}
+
+		if adaptive && maxCount < count {
+			return fmt.Errorf("--max-count (%d) must not be lower than --count (%d)", maxCount, count)
+		}
+
+		if err := preflight(logger, hostRoot, force); err != nil {
+			return err
+		}
+
+		logger.Info("running benchmarks", "count", count, "adaptive", adaptive)
 
 		basePath := cmd.Flag("benchmarks").Value.String()
 		logger.Debug("benchmarks directory", "basePath", basePath)
@@ -32,24 +92,186 @@ var runCmd = &cobra.Command{
 			return fmt.Errorf("benchmarks directory does not exist: %s", basePath)
 		}
 
+		defaults, err := loadRunFileConfig(basePath)
+		if err != nil {
+			return err
+		}
+
+		calibration, err := loadCalibration(basePath)
+		if err != nil {
+			return err
+		}
+		if calibration == nil {
+			logger.Warn("machine is not calibrated, results cannot be normalized across machines (see the calibrate command)")
+		}
+
+		var noise *noiseSentinel
+		if sentinel || discardNoisy {
+			noise = newNoiseSentinel(sentinelThreshold, discardNoisy)
+		}
+
+		if interleave && seed == 0 {
+			seed = newSeed()
+		}
+
+		opts := runOptions{
+			all:      all,
+			count:    count,
+			adaptive: adaptive,
+			stopping: adaptiveOptions{
+				targetCI: targetCI,
+				maxCount: maxCount,
+				maxTime:  maxTime,
+			},
+			interleave:  interleave,
+			seed:        seed,
+			host:        host{root: hostRoot},
+			sentinel:    noise,
+			calibration: calibration,
+			cooldown: cooldownOptions{
+				minPause: time.Second,
+				maxTemp:  maxTemp,
+				maxLoad:  maxLoad,
+				timeout:  cooldownTimeout,
+			},
+			deps:       deps,
+			goVersions: goVersions,
+			variants:   variants,
+			pgo:        pgo,
+			profiles:   profiles,
+			sizes:      sizes,
+			sweeps:     sweeps,
+			defaults:   defaults,
+		}
+
 		// Walk through the benchmarks directory
-		err := utils.WalkOverBenchmarks(basePath, func(path string) error {
-			return runBenchmark(logger, path, all, count)
+		err = utils.WalkOverBenchmarks(basePath, func(path string) error {
+			return runBenchmark(logger, path, opts)
 		})
+		if err != nil {
+			return err
+		}
 
-		return err
+		if bundle != "" {
+			return writeBundle(logger, basePath, bundle, keyFile, bundleEnvironmentOf(opts.host, calibration))
+		}
+		return nil
 	},
 }
 
-func runBenchmark(logger *slog.Logger, path string, all bool, count int) error {
+// runOptions holds the settings of a run.
+type runOptions struct {
+	all         bool                    // Re-run groups that already have output
+	count       int                     // Number of repetitions, the minimum if adaptive
+	adaptive    bool                    // Repeat until results are stable
+	stopping    adaptiveOptions         // When adaptive runs stop
+	interleave  bool                    // Run every benchmark function, CPU count and benchtime separately in random order
+	seed        int64                   // Seed of the random order
+	host        host                    // Machine whose temperature and load are read
+	cooldown    cooldownOptions         // Pause between runs
+	sentinel    *noiseSentinel          // Noise sentinel shared by all groups of the session, nil if disabled
+	calibration *parser.Calibration     // Calibration of the machine, nil if not calibrated
+	deps        map[string][]string     // Dependency versions to benchmark against, by module path
+	goVersions  []string                // Toolchains to run with
+	variants    map[string]buildVariant // Build variants to run besides the baseline
+	pgo         bool                    // Also run every config rebuilt with a CPU profile of itself
+	profiles    []string                // Profile kinds to collect per benchmark function
+	sizes       sizeSweep               // Input sizes to run with
+	sweeps      map[string][]string     // Constant values to run with, by constant name
+	defaults    runFileConfig           // _run.yml of the benchmarks directory
+}
+
+func runBenchmark(logger *slog.Logger, path string, opts runOptions) error {
 	outputFilePath := filepath.Join(path, "_bench.out")
 	logger.Debug("running benchmark", "path", path)
 
-	if _, err := os.Stat(outputFilePath); err == nil && !all {
+	if _, err := os.Stat(outputFilePath); err == nil && !opts.all {
 		logger.Debug("benchmark output already exists, skipping", "path", path)
 		return nil
 	}
 
+	groupConfig, err := loadRunFileConfig(path)
+	if err != nil {
+		return err
+	}
+
+	required, err := requiredModules(path)
+	if err != nil {
+		return err
+	}
+
+	// Flags take precedence over the group's _run.yml, which takes
+	// precedence over the defaults. Dependency versions from flags and
+	// defaults only apply to groups that require the module.
+	deps := make(map[string][]string)
+	for module, versions := range opts.defaults.Dependencies {
+		if required[module] {
+			deps[module] = versions
+		}
+	}
+	maps.Copy(deps, groupConfig.Dependencies)
+	for module, versions := range opts.deps {
+		if required[module] {
+			deps[module] = versions
+		}
+	}
+
+	goVersions := opts.goVersions
+	if len(goVersions) == 0 {
+		goVersions = groupConfig.Go
+	}
+	if len(goVersions) == 0 {
+		goVersions = opts.defaults.Go
+	}
+	toolchains, err := resolveToolchains(goVersions, append(groupConfig.GoRoots, opts.defaults.GoRoots...))
+	if err != nil {
+		return err
+	}
+
+	variants := opts.variants
+	if len(variants) == 0 {
+		variants = groupConfig.Variants
+	}
+	if len(variants) == 0 {
+		variants = opts.defaults.Variants
+	}
+
+	// Size and constant sweeps from flags only apply to groups that declare
+	// the constant; flags take precedence over the group's _run.yml.
+	declared, err := declaredConsts(path)
+	if err != nil {
+		return err
+	}
+	sizes := groupConfig.Sizes
+	if declared[opts.sizes.Const] {
+		sizes = opts.sizes
+	}
+	sweeps := maps.Clone(groupConfig.Sweeps)
+	if sweeps == nil {
+		sweeps = make(map[string][]string)
+	}
+	for name, values := range opts.sweeps {
+		if declared[name] {
+			sweeps[name] = values
+		}
+	}
+	if _, ok := sweeps[sizes.Const]; ok && len(sizes.Values) > 0 {
+		return fmt.Errorf("constant %s is swept as the input size and cannot be swept again", sizes.Const)
+	}
+
+	configs := []runConfig{{}}
+	configs = expandSizes(configs, sizes)
+	configs = expandSweeps(configs, sweeps)
+	configs = expandDependencies(configs, deps)
+	configs = expandToolchains(configs, toolchains)
+	configs = expandVariants(configs, variants)
+
+	cleanup, err := prepareRunConfigs(logger, path, configs)
+	defer cleanup()
+	if err != nil {
+		return fmt.Errorf("failed to set up run matrix: %w", err)
+	}
+
 	maxCPU := runtime.NumCPU()
 	logger.Debug("max cpu", "maxCPU", maxCPU)
 
@@ -60,39 +282,255 @@ func runBenchmark(logger *slog.Logger, path string, all bool, count int) error {
 
 	logger.Debug("cpu tests", "cpuTests", cpuTests)
 
+	if opts.pgo || groupConfig.PGO || opts.defaults.PGO {
+		pgoDir, err := os.MkdirTemp("", "gobench-pgo-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(pgoDir)
+
+		logger.Info("collecting pgo profiles", "path", path)
+		configs, err = expandPGO(logger, configs, strings.Join(cpuTests, ","), pgoDir)
+		if err != nil {
+			return err
+		}
+	}
+
 	benchtimes := []string{"1000x", "2000x", "3000x", "4000x", "5000x", "6000x", "7000x", "8000x", "9000x", "10000x"}
 	var output []byte
+	samples := make(map[string][]float64) // ns/op of every run, by result
+	// The sources are hashed before running, so bundles can tell which
+	// sources the results were recorded with.
+	sources, err := sourceHashes(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash group sources: %w", err)
+	}
+	record := parser.RunRecord{Adaptive: opts.adaptive, Order: "sequential", Calibration: opts.calibration, Sources: sources}
+	if opts.adaptive {
+		record.TargetCI = opts.stopping.targetCI
+	}
+
+	var invocations []benchInvocation // Set up below if interleaved
+	var rng *rand.Rand
+
+	// Results of the current run, kept back until the run turned out not
+	// to be noisy.
+	var runOutput []byte
+	runSamples := make(map[string][]float64)
+
+	// runCommand runs a benchmark command of config and collects its results.
+	runCommand := func(config runConfig, cmd *exec.Cmd) error {
+		logger.Debug("executing benchmark command", "command", cmd.String(), "path", config.dir, "labels", config.labels)
+		result, err := cmd.Output()
+		if err != nil {
+			logger.Error("failed to run benchmark", "path", path, "output", string(result))
+			return fmt.Errorf("failed to run benchmark: %w", err)
+		}
+		runOutput = append(runOutput, labelLines(config.labels)...)
+		runOutput = append(runOutput, result...)
+
+		for _, line := range strings.Split(string(result), "\n") {
+			if b, err := parse.ParseLine(line); err == nil {
+				key := fmt.Sprintf("%s%s/%d", labelLines(config.labels), b.Name, b.N)
+				runSamples[key] = append(runSamples[key], b.NsPerOp)
+			}
+		}
+		return nil
+	}
+
+	// runOnce runs all benchmarks once, in the configured order.
+	runOnce := func() error {
+		if opts.interleave {
+			for _, inv := range shuffleInvocations(invocations, rng) {
+				if err := runCommand(inv.config, inv.command()); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for _, config := range configs {
+			for _, benchtime := range benchtimes {
+				cmd := config.goCommand("test", "-bench", ".", "-benchmem", "-benchtime", benchtime, "-cpu", strings.Join(cpuTests, ","))
+				if err := runCommand(config, cmd); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if opts.interleave {
+		binDir, err := os.MkdirTemp("", "gobench-interleave-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %w", err)
+		}
+		defer os.RemoveAll(binDir)
+
+		invocations, err = interleavedInvocations(configs, cpuTests, benchtimes, binDir)
+		if err != nil {
+			return err
+		}
+		rng = rand.New(rand.NewPCG(uint64(opts.seed), 0))
+		record.Order = "interleaved"
+		record.Seed = opts.seed
+		logger.Info("running interleaved", "invocations", len(invocations), "seed", opts.seed, "path", path)
+	}
+
+	var sentinelStart int
+	if opts.sentinel != nil {
+		sentinelStart = len(opts.sentinel.readings)
+		record.Noise = &parser.NoiseRecord{Threshold: opts.sentinel.threshold}
+	}
+
+	start := time.Now()
 
-	// Run benchmarks sequentially count times to reduce variance.
-	for run := range count {
+	// Run benchmarks sequentially count times to reduce variance. Adaptive
+	// runs continue until the results are stable or the budget is spent.
+	for run := 0; ; run++ {
+		if run >= opts.count {
+			if !opts.adaptive {
+				break
+			}
+			if reason := opts.stopping.stopReason(samples, run, time.Since(start)); reason != "" {
+				record.StopReason = reason
+				break
+			}
+		}
+		record.Runs = run + 1
+
+		conditions := parser.RunConditions{Run: run + 1}
 		if run > 0 {
-			logger.Debug("sleeping 1s between runs for CPU cooldown")
-			time.Sleep(time.Second)
+			waited, timedOut := opts.cooldown.wait(logger, opts.host, runtime.NumCPU())
+			conditions.Cooldown = waited.Seconds()
+			conditions.CooldownTimedOut = timedOut
 		}
+		current := opts.host.conditions(runtime.NumCPU())
+		conditions.Temperature, conditions.Load = current.Temperature, current.Load
 
-		logger.Info("benchmark run", "run", run+1, "total", count, "path", path)
+		if opts.adaptive && run >= opts.count {
+			width, _ := widestMedianCI(samples)
+			logger.Info("benchmark run", "run", run+1, "max", opts.stopping.maxCount, "widest_ci", width, "path", path)
+		} else {
+			logger.Info("benchmark run", "run", run+1, "total", opts.count, "path", path)
+		}
+
+		// The noise sentinel is read right before and after the run; the
+		// run is noisy if the machine deviated at either point.
+		if opts.sentinel != nil {
+			conditions.Sentinel, conditions.Deviation = opts.sentinel.read()
+		}
 
-		for _, benchtime := range benchtimes {
-			cmd := exec.Command("go", "test", "-bench", ".", "-benchmem", "-benchtime", benchtime, "-cpu", strings.Join(cpuTests, ","))
-			logger.Debug("executing benchmark command", "command", cmd.String(), "path", path)
-			cmd.Dir = path
-			result, err := cmd.Output()
-			if err != nil {
-				logger.Error("failed to run benchmark", "path", path, "output", string(output))
-				return fmt.Errorf("failed to run benchmark: %w", err)
+		runOutput = nil
+		clear(runSamples)
+		if err := runOnce(); err != nil {
+			return err
+		}
+
+		if opts.sentinel != nil {
+			_, after := opts.sentinel.read()
+			conditions.Deviation = max(conditions.Deviation, after)
+			conditions.Noisy = conditions.Deviation > opts.sentinel.threshold
+		}
+		record.Conditions = append(record.Conditions, conditions)
+
+		if conditions.Noisy {
+			record.Noise.NoisyRuns++
+			if opts.sentinel.discard {
+				record.Noise.DiscardedRuns++
+				record.Conditions[len(record.Conditions)-1].Discarded = true
+				logger.Warn("discarding noisy run", "run", run+1, "deviation", conditions.Deviation, "path", path)
+				continue
 			}
-			output = append(output, result...)
+			logger.Warn("noisy run", "run", run+1, "deviation", conditions.Deviation, "path", path)
+		}
+
+		output = append(output, runOutput...)
+		for key, vals := range runSamples {
+			samples[key] = append(samples[key], vals...)
+		}
+	}
+
+	if opts.sentinel != nil {
+		record.Noise.Baseline = opts.sentinel.baseline()
+		record.Noise.Score = noiseScore(opts.sentinel.readings[sentinelStart:])
+		record.Noise.SessionScore = noiseScore(opts.sentinel.readings)
+		logger.Info("noise", "score", record.Noise.Score, "session_score", record.Noise.SessionScore, "noisy_runs", record.Noise.NoisyRuns, "path", path)
+	}
+
+	if len(opts.profiles) > 0 {
+		logger.Info("collecting profiles", "path", path, "profiles", opts.profiles)
+		if len(configs) > 1 {
+			logger.Warn("profiles cover only the first cell of the run matrix", "path", path, "labels", configs[0].labels, "cells", len(configs))
+		}
+		if err := runProfiles(logger, configs[0], path, opts.profiles); err != nil {
+			return err
 		}
 	}
 
+	record.Seconds = time.Since(start).Seconds()
+	if width, ok := widestMedianCI(samples); ok {
+		record.MedianCI = width
+	}
+	if record.StopReason != "" {
+		logger.Info("adaptive runs stopped", "reason", record.StopReason, "runs", record.Runs, "widest_ci", record.MedianCI, "path", path)
+	}
+	if err := writeRunRecord(path, record); err != nil {
+		return err
+	}
+
 	logger.Info("writing benchmark output", "path", path+string(os.PathSeparator)+"_bench.out")
 	return os.WriteFile(outputFilePath, output, 0644)
 }
 
+// moduleEnv returns the environment for running go commands in a benchmark
+// group. Groups with their own go.mod are built offline: from the vendor
+// directory if present, otherwise from the module cache. Dependencies have
+// to be downloaded beforehand with "go mod download" or "go mod vendor".
+func moduleEnv(path string) []string {
+	env := os.Environ()
+	if _, err := os.Stat(filepath.Join(path, "go.mod")); err != nil {
+		return env
+	}
+
+	mod := "-mod=readonly"
+	if _, err := os.Stat(filepath.Join(path, "vendor")); err == nil {
+		mod = "-mod=vendor"
+	}
+
+	return append(env,
+		"GOPROXY=off",
+		"GOFLAGS="+strings.TrimSpace(os.Getenv("GOFLAGS")+" "+mod),
+	)
+}
+
 func init() {
 	runCmd.Flags().StringP("benchmarks", "b", "../benchmarks", "Filepath of the \"benchmarks\" directory")
 	runCmd.Flags().BoolP("all", "a", false, "Re-run all benchmarks, overwriting existing output files")
 	runCmd.Flags().IntP("count", "c", 10, "Number of times to run each benchmark (results are averaged)")
+	runCmd.Flags().Bool("adaptive", false, "Repeat runs beyond --count until the 95% confidence interval of every median ns/op is within --target-ci")
+	runCmd.Flags().Float64("target-ci", 0.02, "Relative width of the confidence interval adaptive runs aim for (0.02 = ±1% around the median)")
+	runCmd.Flags().Int("max-count", 50, "Maximum number of adaptive runs")
+	runCmd.Flags().Duration("max-time", 0, "Maximum time spent on adaptive runs of a group, e.g. 10m (0 for no limit)")
+	runCmd.Flags().Bool("interleave", false, "Run every benchmark function, CPU count and benchtime as its own invocation, shuffled anew each run")
+	runCmd.Flags().Int64("seed", 0, "Seed of the --interleave order (0 picks a random seed, which is recorded in _run.json)")
+	runCmd.Flags().Bool("force", false, "Run even if environment checks fail")
+	runCmd.Flags().String("host-root", "/", "Directory containing the /proc and /sys trees to inspect and read temperature and load from")
+	runCmd.Flags().Float64("max-temp", 70, "Wait between runs until the hottest thermal zone is at most this many °C")
+	runCmd.Flags().Float64("max-load", 0.5, "Wait between runs until the 1-minute load average per CPU is at most this")
+	runCmd.Flags().Duration("cooldown-timeout", 30*time.Second, "Longest wait between runs for the machine to cool down")
+	runCmd.Flags().Bool("sentinel", false, "Read a fixed-cost noise sentinel before and after every run to flag runs taken on a noisy machine (adds a few milliseconds per run)")
+	runCmd.Flags().Float64("sentinel-threshold", 0.1, "Relative deviation of the sentinel from its session baseline above which a run is noisy (0.1 = 10%)")
+	runCmd.Flags().Bool("discard-noisy", false, "Drop the results of noisy runs instead of only flagging them (implies --sentinel)")
+	runCmd.Flags().String("bundle", "", "Also write the results of every group to a signed bundle at this path (e.g. results.tar.gz) to contribute with the import command")
+	runCmd.Flags().String("key", defaultKeyFile(), "ed25519 key to sign --bundle with, generated if it does not exist")
+	runCmd.Flags().StringSlice("go", nil, "Run with each of these locally installed toolchains, e.g. go1.22.5,go1.24.0 or local (overrides _run.yml)")
+	runCmd.Flags().StringArray("variant", nil, "Also run with a named build variant, as name=\"KEY=value -flag ...\" (repeatable, overrides _run.yml)")
+	runCmd.Flags().StringArray("sweep", nil, "Run with several values of a constant, as name=v1,v2,... (repeatable, overrides _run.yml)")
+	runCmd.Flags().String("size", "", "Run with several input sizes by setting a constant, as const=n1,n2,... (overrides _run.yml)")
+	runCmd.Flags().StringSlice("profile", nil, "Also profile each benchmark function in isolation: cpu, mem, block and/or mutex (stored in _profiles/<kind>, for the first cell of the run matrix only)")
+	runCmd.Flags().Bool("pgo", false, "Also run every group rebuilt with profile-guided optimization, using a CPU profile of the group's own benchmarks")
+	runCmd.Flags().StringArray("dep", nil, "Benchmark against several versions of a dependency, as module@v1,v2 (repeatable, overrides _run.yml)")
 
 	rootCmd.AddCommand(runCmd)
 }
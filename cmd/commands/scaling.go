@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"cmp"
+	"sort"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// negativeScalingThreshold is the speedup below which a behavior counts as
+// getting slower with more CPUs, rather than just not getting faster.
+const negativeScalingThreshold = 0.9
+
+// scaling analyses how every behavior of a benchmark scales with the number
+// of CPUs, relative to its median ns/op on a single CPU.
+func scaling(bench parser.Benchmark) []parser.Scaling {
+	type seriesKey struct {
+		Variation string
+		GoVersion string
+		Variant   string
+		Labels    string
+	}
+
+	series := groupSeries(bench.Variations,
+		func(v parser.Variation) seriesKey {
+			return seriesKey{Variation: v.Name, GoVersion: v.GoVersion, Variant: v.Variant, Labels: labelsKey(v.Labels)}
+		},
+		func(a, b seriesKey) int { return cmp.Compare(a.Variation, b.Variation) })
+
+	var result []parser.Scaling
+	for _, vs := range series {
+		key := vs.key
+		byCPU := make(map[int][]parser.Variation)
+		for _, v := range vs.variations {
+			byCPU[v.CPUCount] = append(byCPU[v.CPUCount], v)
+		}
+		if len(byCPU) < 2 || byCPU[1] == nil {
+			continue
+		}
+
+		cpus := make([]int, 0, len(byCPU))
+		for cpu := range byCPU {
+			cpus = append(cpus, cpu)
+		}
+		sort.Ints(cpus)
+
+		nsPerOp := func(v parser.Variation) float64 { return v.NsPerOp }
+		base := medianFloat(byCPU[1], nsPerOp)
+		if base == 0 {
+			continue
+		}
+
+		s := parser.Scaling{
+			Variation: key.Variation,
+			GoVersion: key.GoVersion,
+			Variant:   key.Variant,
+			Labels:    vs.labels,
+		}
+		var ps, speedups []float64
+		for _, cpu := range cpus {
+			ns := medianFloat(byCPU[cpu], nsPerOp)
+			speedup := base / ns
+			s.Points = append(s.Points, parser.ScalingPoint{
+				CPUCount:   cpu,
+				NsPerOp:    ns,
+				Speedup:    speedup,
+				Efficiency: speedup / float64(cpu),
+			})
+			if cpu > 1 {
+				ps = append(ps, float64(cpu))
+				speedups = append(speedups, speedup)
+			}
+		}
+
+		s.SerialFraction = amdahlSerialFraction(ps, speedups)
+		s.ScaledSerialFraction = gustafsonSerialFraction(ps, speedups)
+		s.NegativeScaling = s.Points[len(s.Points)-1].Speedup < negativeScalingThreshold
+
+		result = append(result, s)
+	}
+
+	return result
+}
+
+// amdahlSerialFraction fits Amdahl's law, S(p) = 1 / (s + (1-s)/p), to the
+// speedups S measured with p CPUs and returns the serial fraction s. The
+// law is linear in s as 1/S - 1/p = s * (1 - 1/p), which is solved by least
+// squares. Values above 1 mean the behavior gets slower with more CPUs.
+func amdahlSerialFraction(ps, speedups []float64) float64 {
+	var num, den float64
+	for i, p := range ps {
+		a := 1/speedups[i] - 1/p
+		b := 1 - 1/p
+		num += a * b
+		den += b * b
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
+
+// gustafsonSerialFraction fits Gustafson's law, S(p) = p - s * (p - 1), to
+// the speedups S measured with p CPUs and returns the serial fraction s of
+// the scaled workload, solved by least squares.
+func gustafsonSerialFraction(ps, speedups []float64) float64 {
+	var num, den float64
+	for i, p := range ps {
+		num += (p - speedups[i]) * (p - 1)
+		den += (p - 1) * (p - 1)
+	}
+	if den == 0 {
+		return 0
+	}
+	return num / den
+}
@@ -0,0 +1,47 @@
+package commands
+
+import (
+	"math"
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestScaling(t *testing.T) {
+	variation := func(name string, cpu int, nsPerOp float64) parser.Variation {
+		v := parser.Variation{Name: name, CPUCount: cpu}
+		v.NsPerOp = nsPerOp
+		return v
+	}
+
+	// "half" has a serial fraction of 0.5: S(p) = 1 / (0.5 + 0.5/p).
+	// "contended" gets slower with every CPU added.
+	bench := parser.Benchmark{Variations: []parser.Variation{
+		variation("half", 1, 100),
+		variation("half", 2, 75),
+		variation("half", 4, 62.5),
+		variation("contended", 1, 10),
+		variation("contended", 2, 20),
+		variation("contended", 4, 30),
+		variation("single", 1, 10),
+	}}
+
+	result := scaling(bench)
+	if len(result) != 2 {
+		t.Fatalf("expected 2 results (single CPU count skipped), got %d", len(result))
+	}
+
+	contended, half := result[0], result[1]
+	if math.Abs(half.SerialFraction-0.5) > 1e-9 {
+		t.Errorf("expected serial fraction of 0.5, got %f", half.SerialFraction)
+	}
+	if p := half.Points[2]; p.CPUCount != 4 || p.Speedup != 1.6 || p.Efficiency != 0.4 {
+		t.Errorf("unexpected point: %+v", p)
+	}
+	if half.NegativeScaling {
+		t.Error("expected no negative scaling for half")
+	}
+	if !contended.NegativeScaling || contended.SerialFraction <= 1 {
+		t.Errorf("expected negative scaling for contended: %+v", contended)
+	}
+}
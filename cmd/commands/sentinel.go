@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// sentinelIterations is the fixed amount of work of one sentinel
+	// measurement, a few milliseconds on current machines.
+	sentinelIterations = 2_000_000
+	// sentinelRepeats is the number of back-to-back measurements whose
+	// median is one sentinel reading, so a single preemption is ignored.
+	sentinelRepeats = 3
+	// sentinelCalibrationReadings is the number of readings taken on first
+	// use to establish the baseline of the session.
+	sentinelCalibrationReadings = 5
+)
+
+// sentinelSink keeps the compiler from eliminating the sentinel workload.
+var sentinelSink uint64
+
+// noiseSentinel tracks a fixed-cost workload over a session of runs. The
+// workload never changes, so deviations of its speed from the session's
+// baseline mean the machine got noisier (frequency changes, background load)
+// rather than an implementation getting slower.
+type noiseSentinel struct {
+	threshold float64        // Relative deviation from the baseline above which a run is noisy
+	discard   bool           // Drop the results of noisy runs instead of only flagging them
+	measure   func() float64 // Takes one reading in ns per iteration
+	readings  []float64      // All readings of the session
+}
+
+func newNoiseSentinel(threshold float64, discard bool) *noiseSentinel {
+	return &noiseSentinel{threshold: threshold, discard: discard, measure: sentinelReading}
+}
+
+// read takes a reading and returns it with its relative deviation from the
+// session's baseline. The first reading of a session calibrates the baseline.
+func (s *noiseSentinel) read() (float64, float64) {
+	if len(s.readings) == 0 {
+		for range sentinelCalibrationReadings {
+			s.readings = append(s.readings, s.measure())
+		}
+	}
+
+	v := s.measure()
+	s.readings = append(s.readings, v)
+	return v, s.deviation(v)
+}
+
+// baseline returns the median of all readings of the session.
+func (s *noiseSentinel) baseline() float64 {
+	return median(s.readings)
+}
+
+// deviation returns the relative deviation of a reading from the baseline.
+func (s *noiseSentinel) deviation(v float64) float64 {
+	b := s.baseline()
+	if b == 0 {
+		return 0
+	}
+	return math.Abs(v-b) / b
+}
+
+// noiseScore returns the coefficient of variation (standard deviation over
+// mean) of sentinel readings: 0 on a perfectly quiet machine, a few percent
+// on a typical desktop.
+func noiseScore(readings []float64) float64 {
+	if len(readings) < 2 {
+		return 0
+	}
+
+	mean := 0.0
+	for _, v := range readings {
+		mean += v
+	}
+	mean /= float64(len(readings))
+	if mean == 0 {
+		return 0
+	}
+
+	variance := 0.0
+	for _, v := range readings {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(readings) - 1)
+
+	return math.Sqrt(variance) / mean
+}
+
+// sentinelReading runs the sentinel workload and returns the median time per
+// iteration in ns.
+func sentinelReading() float64 {
+	var times []float64
+	for range sentinelRepeats {
+		start := time.Now()
+		sentinelSink += sentinelWork(sentinelIterations)
+		times = append(times, float64(time.Since(start).Nanoseconds())/sentinelIterations)
+	}
+	return median(times)
+}
+
+// sentinelWork is the sentinel workload: a xorshift generator, which is
+// pure integer arithmetic without memory accesses or allocations.
+func sentinelWork(n int) uint64 {
+	x := uint64(88172645463325252)
+	for range n {
+		x ^= x << 13
+		x ^= x >> 7
+		x ^= x << 17
+	}
+	return x
+}
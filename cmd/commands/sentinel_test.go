@@ -0,0 +1,48 @@
+package commands
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNoiseSentinel(t *testing.T) {
+	readings := []float64{1, 1, 1, 1, 1, 1.02, 1.5}
+	s := newNoiseSentinel(0.1, false)
+	s.measure = func() float64 {
+		v := readings[0]
+		readings = readings[1:]
+		return v
+	}
+
+	// The first read calibrates the baseline with five readings.
+	if v, deviation := s.read(); v != 1.02 || math.Abs(deviation-0.02) > 1e-9 {
+		t.Errorf("expected 1.02 deviating by 2%%, got %v deviating by %v", v, deviation)
+	}
+	if len(s.readings) != 6 {
+		t.Errorf("expected 6 readings, got %d", len(s.readings))
+	}
+
+	if _, deviation := s.read(); deviation <= s.threshold {
+		t.Errorf("expected 1.5 to deviate beyond the threshold, got %v", deviation)
+	}
+	if s.baseline() != 1 {
+		t.Errorf("expected a baseline of 1, got %v", s.baseline())
+	}
+}
+
+func TestNoiseScore(t *testing.T) {
+	if score := noiseScore([]float64{2, 2, 2}); score != 0 {
+		t.Errorf("expected 0 for constant readings, got %v", score)
+	}
+	// Mean 2, sample standard deviation 1.
+	if score := noiseScore([]float64{1, 2, 3}); score != 0.5 {
+		t.Errorf("expected 0.5, got %v", score)
+	}
+	if score := noiseScore([]float64{1}); score != 0 {
+		t.Errorf("expected 0 for a single reading, got %v", score)
+	}
+
+	if v := sentinelReading(); v <= 0 {
+		t.Errorf("expected a positive sentinel reading, got %v", v)
+	}
+}
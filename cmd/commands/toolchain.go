@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// toolchain is a locally installed Go SDK.
+type toolchain struct {
+	bin     string // Path of the go command
+	version string // GOVERSION reported by the toolchain, e.g. "go1.22.5"
+}
+
+// resolveToolchains resolves toolchain names to locally installed SDKs.
+// A name is looked up, in order, as:
+//
+//   - "local": the go command on PATH
+//   - an SDK installed by golang.org/dl in ~/sdk/<name> (e.g. "go1.22.5", "gotip")
+//   - one of the given GOROOT directories whose toolchain reports the
+//     version <name> or whose directory is named <name>
+func resolveToolchains(names []string, goroots []string) ([]toolchain, error) {
+	home, _ := os.UserHomeDir()
+
+	var toolchains []toolchain
+	for _, name := range names {
+		tc, err := resolveToolchain(name, home, goroots)
+		if err != nil {
+			return nil, err
+		}
+		toolchains = append(toolchains, tc)
+	}
+
+	return toolchains, nil
+}
+
+func resolveToolchain(name, home string, goroots []string) (toolchain, error) {
+	if name == "local" {
+		bin, err := exec.LookPath("go")
+		if err != nil {
+			return toolchain{}, fmt.Errorf("failed to find go on PATH: %w", err)
+		}
+		return newToolchain(bin)
+	}
+
+	searched := []string{}
+	if home != "" {
+		bin := filepath.Join(home, "sdk", name, "bin", "go")
+		if _, err := os.Stat(bin); err == nil {
+			return newToolchain(bin)
+		}
+		searched = append(searched, filepath.Dir(filepath.Dir(bin)))
+	}
+
+	for _, goroot := range goroots {
+		bin := filepath.Join(goroot, "bin", "go")
+		if _, err := os.Stat(bin); err != nil {
+			continue
+		}
+
+		tc, err := newToolchain(bin)
+		if err != nil {
+			return toolchain{}, err
+		}
+		if tc.version == name || filepath.Base(filepath.Clean(goroot)) == name {
+			return tc, nil
+		}
+		searched = append(searched, goroot)
+	}
+
+	return toolchain{}, fmt.Errorf("toolchain %s not found (searched %s); install it with \"go install golang.org/dl/%s@latest && %s download\" or add its GOROOT to _run.yml", name, strings.Join(searched, ", "), name, name)
+}
+
+// newToolchain queries the version of the go command at bin.
+func newToolchain(bin string) (toolchain, error) {
+	cmd := exec.Command(bin, "env", "GOVERSION")
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	out, err := cmd.Output()
+	if err != nil {
+		return toolchain{}, fmt.Errorf("failed to query version of %s: %w", bin, err)
+	}
+
+	return toolchain{bin: bin, version: strings.TrimSpace(string(out))}, nil
+}
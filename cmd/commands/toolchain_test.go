@@ -0,0 +1,58 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// writeFakeGo installs a go command below root that reports version.
+func writeFakeGo(t *testing.T, root, version string) string {
+	t.Helper()
+	bin := filepath.Join(root, "bin", "go")
+	if err := os.MkdirAll(filepath.Dir(bin), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho "+version+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return bin
+}
+
+func TestResolveToolchains(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake go commands are shell scripts")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	sdk := writeFakeGo(t, filepath.Join(home, "sdk", "go1.22.5"), "go1.22.5")
+	goroot := filepath.Join(t.TempDir(), "custom")
+	custom := writeFakeGo(t, goroot, "go1.23.1")
+
+	toolchains, err := resolveToolchains([]string{"go1.22.5", "go1.23.1", "custom"}, []string{goroot})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []toolchain{
+		{bin: sdk, version: "go1.22.5"},    // From ~/sdk
+		{bin: custom, version: "go1.23.1"}, // GOROOT by version
+		{bin: custom, version: "go1.23.1"}, // GOROOT by directory name
+	}
+	if len(toolchains) != len(want) {
+		t.Fatalf("expected %d toolchains, got %d: %+v", len(want), len(toolchains), toolchains)
+	}
+	for i := range want {
+		if toolchains[i] != want[i] {
+			t.Errorf("toolchain %d: expected %+v, got %+v", i, want[i], toolchains[i])
+		}
+	}
+
+	_, err = resolveToolchains([]string{"go1.21.0"}, []string{goroot})
+	if err == nil || !strings.Contains(err.Error(), "golang.org/dl/go1.21.0") {
+		t.Errorf("expected an install hint for a missing toolchain, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"cmp"
+	"sort"
+	"strings"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+// groupVariants returns all build variants a group was run with, sorted by
+// name. The baseline build is not included.
+func groupVariants(group parser.BenchmarkGroup) []string {
+	seen := make(map[string]bool)
+	var variants []string
+	for _, bench := range group.Benchmarks {
+		for _, v := range bench.Variations {
+			if v.Variant != "" && !seen[v.Variant] {
+				seen[v.Variant] = true
+				variants = append(variants, v.Variant)
+			}
+		}
+	}
+
+	sort.Strings(variants)
+	return variants
+}
+
+// variantResults compares the median ns/op of every behavior under each
+// build variant with the build it is compared with (see comparedVariant) of
+// the same CPU count, toolchain and labels, and marks the fastest behavior of
+// each build.
+func variantResults(bench parser.Benchmark) []parser.VariantResult {
+	// buildKey identifies one build of the benchmark (all behaviors).
+	type buildKey struct {
+		Variant   string
+		CPUCount  int
+		GoVersion string
+		Labels    string
+	}
+	type seriesKey struct {
+		buildKey
+		Variation string
+	}
+
+	series := groupSeries(bench.Variations,
+		func(v parser.Variation) seriesKey {
+			return seriesKey{
+				buildKey:  buildKey{Variant: v.Variant, CPUCount: v.CPUCount, GoVersion: v.GoVersion, Labels: labelsKey(v.Labels)},
+				Variation: v.Name,
+			}
+		},
+		func(a, b seriesKey) int {
+			return cmp.Or(cmp.Compare(a.Variant, b.Variant), cmp.Compare(a.Variation, b.Variation), cmp.Compare(a.CPUCount, b.CPUCount))
+		})
+
+	nsPerOp := make(map[seriesKey]float64, len(series))
+	fastest := make(map[buildKey]float64)
+	for _, vs := range series {
+		ns := medianFloat(vs.variations, func(v parser.Variation) float64 { return v.NsPerOp })
+		nsPerOp[vs.key] = ns
+		if best, ok := fastest[vs.key.buildKey]; !ok || ns < best {
+			fastest[vs.key.buildKey] = ns
+		}
+	}
+
+	var results []parser.VariantResult
+	for _, vs := range series {
+		key := vs.key
+		if key.Variant == "" {
+			continue
+		}
+
+		baseKey := key
+		baseKey.Variant = comparedVariant(key.Variant)
+		base, ok := nsPerOp[baseKey]
+		if !ok || base == 0 {
+			continue
+		}
+
+		ns := nsPerOp[key]
+		results = append(results, parser.VariantResult{
+			Variant:         key.Variant,
+			Variation:       key.Variation,
+			CPUCount:        key.CPUCount,
+			GoVersion:       key.GoVersion,
+			Labels:          vs.labels,
+			Baseline:        baseKey.Variant,
+			NsPerOpBaseline: base,
+			NsPerOp:         ns,
+			Change:          (ns - base) / base,
+			FastestBaseline: base == fastest[baseKey.buildKey],
+			Fastest:         ns == fastest[key.buildKey],
+		})
+	}
+
+	return results
+}
+
+// comparedVariant returns the build variant that variant is compared with:
+// the variant without PGO for "<variant>+pgo", so that the PGO gain does not
+// include the effect of the variant itself, and the baseline build ("")
+// otherwise.
+func comparedVariant(variant string) string {
+	base, _ := strings.CutSuffix(variant, pgoVariantSuffix)
+	if base == variant {
+		return ""
+	}
+	return base
+}
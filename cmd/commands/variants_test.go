@@ -0,0 +1,38 @@
+package commands
+
+import (
+	"testing"
+
+	"github.com/marvinjwendt/gobench/cmd/internal/parser"
+)
+
+func TestVariantResults(t *testing.T) {
+	variation := func(name, variant string, nsPerOp float64) parser.Variation {
+		v := parser.Variation{Name: name, CPUCount: 1, Variant: variant}
+		v.NsPerOp = nsPerOp
+		return v
+	}
+
+	bench := parser.Benchmark{Variations: []parser.Variation{
+		variation("interface", "", 100),
+		variation("direct", "", 80),
+		variation("interface", "pgo", 60),
+		variation("direct", "pgo", 80),
+	}}
+
+	results := variantResults(bench)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d: %+v", len(results), results)
+	}
+
+	r := results[1]
+	if r.Variation != "interface" || r.Variant != "pgo" {
+		t.Fatalf("unexpected order: %+v", results)
+	}
+	if r.Change != -0.4 {
+		t.Errorf("expected change of -0.4, got %f", r.Change)
+	}
+	if r.FastestBaseline || !r.Fastest {
+		t.Errorf("expected interface to become the fastest with pgo: %+v", r)
+	}
+}
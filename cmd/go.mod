@@ -5,7 +5,9 @@ go 1.25.5
 require (
 	github.com/dave/dst v0.27.3
 	github.com/goccy/go-yaml v1.19.2
+	github.com/google/pprof v0.0.0-20260115054156-294ebfa9ad83
 	github.com/spf13/cobra v1.10.2
+	golang.org/x/mod v0.33.0
 )
 
 require (
@@ -24,7 +26,6 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
 	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
-	golang.org/x/mod v0.33.0 // indirect
 	golang.org/x/sync v0.19.0 // indirect
 	golang.org/x/sys v0.41.0 // indirect
 )
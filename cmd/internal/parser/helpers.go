@@ -0,0 +1,253 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+	"github.com/marvinjwendt/gobench/cmd/internal/utils"
+	"golang.org/x/mod/modfile"
+)
+
+// helperLoader loads shared helper packages from the internal directory of
+// the benchmarks module, so that helpers referenced by a benchmark can be
+// shown alongside its code.
+type helperLoader struct {
+	benchmarksDir string
+	modulePath    string
+	packages      map[string]*sourceIndex // Parsed helper packages by import path
+}
+
+func newHelperLoader(benchmarksDir string) (*helperLoader, error) {
+	data, err := os.ReadFile(filepath.Join(benchmarksDir, "go.mod"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmarks go.mod: %w", err)
+	}
+
+	modulePath := modfile.ModulePath(data)
+	if modulePath == "" {
+		return nil, fmt.Errorf("benchmarks go.mod has no module path")
+	}
+
+	return &helperLoader{
+		benchmarksDir: benchmarksDir,
+		modulePath:    modulePath,
+		packages:      make(map[string]*sourceIndex),
+	}, nil
+}
+
+// load returns the parsed helper package for importPath, or nil if the
+// import path does not refer to a shared helper package.
+func (l *helperLoader) load(importPath string) (*sourceIndex, error) {
+	rel, ok := strings.CutPrefix(importPath, l.modulePath+"/")
+	if !ok || !strings.HasPrefix(rel, utils.InternalDir+"/") {
+		return nil, nil
+	}
+
+	if idx, ok := l.packages[importPath]; ok {
+		return idx, nil
+	}
+
+	dir := filepath.Join(l.benchmarksDir, filepath.FromSlash(rel))
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read helper package %s: %w", importPath, err)
+	}
+
+	idx := newSourceIndex()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read helper file: %w", err)
+		}
+
+		if err := idx.add(entry.Name(), b); err != nil {
+			return nil, fmt.Errorf("failed to parse helper file: %w", err)
+		}
+	}
+
+	l.packages[importPath] = idx
+	return idx, nil
+}
+
+// inline returns the code and source locations of all helper declarations
+// referenced by decls, which must belong to idx. Declarations the helpers
+// depend on are included as well, both within their own package and in other
+// helper packages. Each run of declarations from the same file is preceded by
+// a comment naming its origin.
+func (l *helperLoader) inline(idx *sourceIndex, decls []dst.Decl) (string, []SourceLocation, error) {
+	// Keep only the names referenced from shared helper packages, following
+	// the references of every helper into further helper packages.
+	referenced := make(map[string]map[string]bool)
+	pending := idx.importedSelectors(decls)
+	for len(pending) > 0 {
+		sel := pending[0]
+		pending = pending[1:]
+		if referenced[sel.importPath][sel.name] {
+			continue
+		}
+
+		helper, err := l.load(sel.importPath)
+		if err != nil {
+			return "", nil, err
+		}
+		if helper == nil {
+			continue
+		}
+
+		if referenced[sel.importPath] == nil {
+			referenced[sel.importPath] = make(map[string]bool)
+		}
+		referenced[sel.importPath][sel.name] = true
+		pending = append(pending, helper.importedSelectors(helper.dependencies(map[string]bool{sel.name: true}))...)
+	}
+
+	importPaths := make([]string, 0, len(referenced))
+	for importPath := range referenced {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+
+	newFile := &dst.File{Name: dst.NewIdent("dummy")}
+	var sources []SourceLocation
+	for _, importPath := range importPaths {
+		helper := l.packages[importPath]
+
+		lastFile := ""
+		for _, decl := range helper.dependencies(referenced[importPath]) {
+			loc := helper.locations[decl]
+			loc.Package = importPath
+			sources = append(sources, loc)
+
+			clone := dst.Clone(decl).(dst.Decl)
+			if loc.File != lastFile {
+				clone.Decorations().Before = dst.EmptyLine
+				clone.Decorations().Start.Prepend("// From " + importPath + " (" + loc.File + ")")
+				lastFile = loc.File
+			}
+			newFile.Decls = append(newFile.Decls, clone)
+		}
+	}
+
+	if len(newFile.Decls) == 0 {
+		return "", nil, nil
+	}
+
+	var buf bytes.Buffer
+	if err := decorator.Fprint(&buf, newFile); err != nil {
+		return "", nil, err
+	}
+
+	code, err := cleanCode(buf.String())
+	return code, sources, err
+}
+
+// dependencies returns the declarations of the given top-level names and of
+// every top-level name they reference in turn, in source order. Methods of
+// included types are included as well. Names qualified by an imported package,
+// selected from a value, or declaring methods, fields and parameters are not
+// references to the top-level names of idx.
+func (idx *sourceIndex) dependencies(names map[string]bool) []dst.Decl {
+	byName := make(map[string][]dst.Decl)
+	for _, decl := range idx.file.Decls {
+		switch d := decl.(type) {
+		case *dst.FuncDecl:
+			if d.Recv == nil {
+				byName[d.Name.Name] = append(byName[d.Name.Name], decl)
+			} else {
+				// Methods are pulled in together with their receiver type.
+				byName[recvTypeName(d)] = append(byName[recvTypeName(d)], decl)
+			}
+		case *dst.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *dst.TypeSpec:
+					byName[s.Name.Name] = append(byName[s.Name.Name], decl)
+				case *dst.ValueSpec:
+					for _, n := range s.Names {
+						byName[n.Name] = append(byName[n.Name], decl)
+					}
+				}
+			}
+		}
+	}
+
+	included := make(map[dst.Decl]bool)
+	seen := make(map[string]bool)
+	var queue []string
+	for name := range names {
+		queue = append(queue, name)
+	}
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		for _, decl := range byName[name] {
+			if included[decl] {
+				continue
+			}
+			included[decl] = true
+
+			imports := idx.imports[idx.locations[decl].File]
+			var visit func(n dst.Node) bool
+			visit = func(n dst.Node) bool {
+				switch n := n.(type) {
+				case *dst.SelectorExpr:
+					if pkg, ok := n.X.(*dst.Ident); ok && imports[pkg.Name] != "" {
+						return false
+					}
+					// Only the operand can refer to a top-level name.
+					dst.Inspect(n.X, visit)
+					return false
+				case *dst.FuncDecl:
+					// Method names do not refer to top-level names.
+					if n.Recv != nil {
+						dst.Inspect(n.Recv, visit)
+						dst.Inspect(n.Type, visit)
+						if n.Body != nil {
+							dst.Inspect(n.Body, visit)
+						}
+						return false
+					}
+				case *dst.Field:
+					// Neither do the names of fields and parameters.
+					dst.Inspect(n.Type, visit)
+					return false
+				case *dst.Ident:
+					if len(byName[n.Name]) > 0 && !seen[n.Name] {
+						queue = append(queue, n.Name)
+					}
+				}
+				return true
+			}
+			dst.Inspect(decl, visit)
+		}
+	}
+
+	var decls []dst.Decl
+	for _, decl := range idx.file.Decls {
+		if included[decl] {
+			decls = append(decls, decl)
+		}
+	}
+
+	return decls
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// MachineFingerprint identifies the kind of machine benchmark output was
+// recorded on by its OS, architecture and CPU model.
+func MachineFingerprint(info SystemInfo) string {
+	sum := sha256.Sum256([]byte(info.GoOS + "/" + info.GoArch + "/" + info.CPU))
+	return hex.EncodeToString(sum[:6])
+}
+
+// ReadMachineFingerprint returns the fingerprint of the machine the
+// benchmark output file at path was recorded on.
+func ReadMachineFingerprint(path string) (string, error) {
+	info, err := parseSystemInfo(path)
+	if err != nil {
+		return "", err
+	}
+	if info.GoOS == "" && info.CPU == "" {
+		return "", fmt.Errorf("no system info in %s", path)
+	}
+	return MachineFingerprint(info), nil
+}
+
+// MachineBenchFile returns the name of the file that benchmark output of the
+// machine with the given fingerprint is merged into, next to the group's own
+// _bench.out.
+func MachineBenchFile(fingerprint string) string {
+	return "_bench." + fingerprint + ".out"
+}
+
+// parseMachineFiles parses the benchmark output merged from other machines
+// into the group at path, ordered by fingerprint.
+func parseMachineFiles(logger *slog.Logger, path string) ([]MachineResults, error) {
+	files, err := filepath.Glob(filepath.Join(path, MachineBenchFile("*")))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var machines []MachineResults
+	for _, file := range files {
+		fingerprint := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "_bench."), ".out")
+
+		sysInfo, variations, err := parseBenchFile(logger, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse results of machine %s: %w", fingerprint, err)
+		}
+
+		byName := make(map[string][]Variation)
+		var names []string
+		for _, v := range variations {
+			if _, ok := byName[v.Benchmark.Name]; !ok {
+				names = append(names, v.Benchmark.Name)
+			}
+			byName[v.Benchmark.Name] = append(byName[v.Benchmark.Name], v)
+		}
+		sort.Strings(names)
+
+		machine := MachineResults{Machine: fingerprint, System: sysInfo}
+		for _, name := range names {
+			machine.Benchmarks = append(machine.Benchmarks, MachineBenchmark{Name: name, Variations: byName[name]})
+		}
+		machines = append(machines, machine)
+	}
+
+	return machines, nil
+}
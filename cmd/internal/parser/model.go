@@ -11,7 +11,10 @@ type SystemInfo struct {
 }
 
 type BenchmarkGroup struct {
-	Dir         string     `json:"-"` // Source directory path (not included in JSON)
+	Dir         string `json:"-"` // Source directory path (not included in JSON)
+	Path        string // Group directory relative to the benchmarks directory (slash-separated)
+	Category    string // Category directories the group is nested in, e.g. "concurrency" (empty for top-level groups)
+	Module      string // Path of the Go module the group is built in
 	Name        string
 	Headline    string
 	Description string
@@ -19,21 +22,306 @@ type BenchmarkGroup struct {
 	Benchmarks  []Benchmark
 	Code        string
 	Constants   string
+	Files       []SourceFile        // Every Go file of the group, as it appears on disk
+	GoVersions  []string            `json:",omitempty"` // Toolchains the group was run with, oldest first
+	Variants    []string            `json:",omitempty"` // Build variants the group was run with, besides the baseline
+	Crossovers  []Crossover         `json:",omitempty"` // Input sizes at which implementations overtake each other
+	Sweeps      []Sweep             `json:",omitempty"` // Constants the group was run with several values of
+	Run         *RunRecord          `json:",omitempty"` // How the results were collected, if recorded
+	Machine     string              // Fingerprint of the machine Benchmarks were recorded on, see MachineFingerprint; empty if the group only has results merged from other machines
+	Machines    []MachineResults    `json:",omitempty"` // Results merged from other machines
+	Consistency []WinnerConsistency `json:",omitempty"` // Whether the fastest implementation is the same on every machine
 }
 
 type Benchmark struct {
-	Name          string // Name of the benchmark
-	Description   string // Description of the benchmark
-	BenchmarkCode string
-	Code          string
-	Variations    []Variation
+	Name             string // Name of the benchmark
+	Description      string // Description of the benchmark
+	BenchmarkCode    string
+	Code             string
+	Sources          []SourceLocation   // Origin of every declaration in BenchmarkCode
+	Dependencies     []Dependency       `json:",omitempty"` // Modules providing the third-party packages the implementation uses
+	GoVersionChanges []GoVersionChange  `json:",omitempty"` // Notable changes between consecutive Go versions
+	Variants         []VariantResult    `json:",omitempty"` // Results under each build variant next to the baseline
+	Profiles         []Profile          `json:",omitempty"` // Summaries of the profiles collected with "run --profile"
+	Assembly         []AssemblyFunction `json:",omitempty"` // Machine code of the implementation's functions (generate --asm)
+	CompilerNotes    []CompilerNote     `json:",omitempty"` // Compiler decisions in the implementation's code (generate --compiler-notes)
+	CostFits         []CostFit          `json:",omitempty"` // Regression of total time over the benchtime sweep
+	Scaling          []Scaling          `json:",omitempty"` // How each behavior scales with the number of CPUs
+	Complexity       []ComplexityFit    `json:",omitempty"` // Complexity class of each behavior over the input sizes
+	Variations       []Variation
+}
+
+// ComplexityFit is the complexity class that best describes how the cost of
+// a behavior grows with the input size: ns/op = Constant + Coefficient * f(n).
+type ComplexityFit struct {
+	Variation     string            // Name of the variation (behavior)
+	CPUCount      int               // Number of CPU cores used
+	GoVersion     string            `json:",omitempty"` // Toolchain the fit was made for
+	Variant       string            `json:",omitempty"` // Build variant the fit was made for
+	Labels        map[string]string `json:",omitempty"` // Other dimensions the fit was made for
+	Class         string            // "O(1)", "O(log n)", "O(n)", "O(n log n)" or "O(n²)"
+	Coefficient   float64           // Factor of f(n), in ns (0 for O(1))
+	Constant      float64           // Constant term, in ns
+	RelativeError float64           // Root mean square relative deviation of the measurements from the fit
+	Sizes         int               // Number of input sizes the fit is based on
+}
+
+// Crossover is the input size at which one implementation overtakes another.
+type Crossover struct {
+	Variation   string            // Name of the variation (behavior)
+	CPUCount    int               // Number of CPU cores used
+	GoVersion   string            `json:",omitempty"` // Toolchain the comparison was made for
+	Variant     string            `json:",omitempty"` // Build variant the comparison was made for
+	Labels      map[string]string `json:",omitempty"` // Other dimensions the comparison was made for
+	Size        float64           // Estimated input size at which both are equally fast
+	FasterBelow string            // Implementation that is faster for smaller inputs
+	FasterAbove string            // Implementation that is faster for larger inputs
+}
+
+// MachineResults are the results of a group recorded on another machine and
+// merged with the merge command.
+type MachineResults struct {
+	Machine    string // Fingerprint of the machine, see MachineFingerprint
+	System     SystemInfo
+	Run        *RunRecord         `json:",omitempty"` // How the results were collected, if recorded
+	Benchmarks []MachineBenchmark // Median variations of each implementation
+}
+
+// MachineBenchmark holds the variations of an implementation on a machine.
+type MachineBenchmark struct {
+	Name       string
+	Variations []Variation
+}
+
+// WinnerConsistency compares the fastest implementation of a behavior across
+// the machines a group was run on.
+type WinnerConsistency struct {
+	Variation  string            // Name of the variation (behavior)
+	CPUCount   int               // Number of CPU cores used
+	GoVersion  string            `json:",omitempty"` // Toolchain the comparison was made for
+	Variant    string            `json:",omitempty"` // Build variant the comparison was made for
+	Labels     map[string]string `json:",omitempty"` // Other dimensions the comparison was made for
+	Winners    map[string]string // Fastest implementation by machine fingerprint
+	Winner     string            // Implementation that is fastest on most machines
+	Agreement  float64           // Share of machines on which Winner is the fastest
+	Consistent bool              // Whether Winner is the fastest on every machine
+}
+
+// Sweep lists the values a constant of a group was run with. Results of
+// each value carry the label "const/<Const>".
+type Sweep struct {
+	Const  string   // Name of the constant
+	Values []string // Values in the order they were run with
+}
+
+// Scaling describes how a behavior scales with the number of CPUs.
+type Scaling struct {
+	Variation            string            // Name of the variation (behavior)
+	GoVersion            string            `json:",omitempty"` // Toolchain the analysis was made for
+	Variant              string            `json:",omitempty"` // Build variant the analysis was made for
+	Labels               map[string]string `json:",omitempty"` // Other dimensions the analysis was made for
+	Points               []ScalingPoint    // Results per CPU count, starting with 1
+	SerialFraction       float64           // Serial fraction estimated with Amdahl's law (fixed workload)
+	ScaledSerialFraction float64           // Serial fraction estimated with Gustafson's law (workload growing with the CPUs)
+	NegativeScaling      bool              // Whether the behavior is notably slower with the most CPUs than with one, e.g. due to contention
+}
+
+// ScalingPoint is the result of a behavior with a given number of CPUs.
+type ScalingPoint struct {
+	CPUCount   int     // Number of CPU cores used
+	NsPerOp    float64 // Median ns/op
+	Speedup    float64 // ns/op with 1 CPU divided by ns/op with CPUCount CPUs
+	Efficiency float64 // Speedup divided by CPUCount, 1 for perfect scaling
+}
+
+// CostFit is a robust linear fit of the total time of a behavior against
+// its iteration count N.
+type CostFit struct {
+	Variation  string            // Name of the variation (behavior)
+	CPUCount   int               // Number of CPU cores used
+	GoVersion  string            `json:",omitempty"` // Toolchain the fit was made for
+	Variant    string            `json:",omitempty"` // Build variant the fit was made for
+	Labels     map[string]string `json:",omitempty"` // Other dimensions the fit was made for
+	NsPerOp    float64           // Slope: the cost of a single operation, in ns
+	OverheadNs float64           // Intercept: the fixed cost of a benchmark run, in ns
+	RSquared   float64           // Coefficient of determination, 1 for a perfectly linear cost
+	Points     int               // Number of iteration counts the fit is based on
+	Linear     bool              // Whether RSquared is high enough for NsPerOp to be meaningful
+}
+
+// CompilerNote is a compiler diagnostic about an implementation's code.
+type CompilerNote struct {
+	Kind    string // "inlined", "not inlined", "escapes" or "bounds check"
+	Name    string // Declaration the note belongs to (see SourceLocation.Name)
+	File    string // File name relative to the group directory
+	Line    int    // Source line
+	Column  int    // Source column
+	Message string // Compiler message, e.g. "inlining call to (*IntCounter).increment"
+}
+
+// AssemblyFunction is the disassembled machine code of a function.
+type AssemblyFunction struct {
+	Symbol       string        // Linker symbol, e.g. "benchmarks/counter.(*IntCounter).increment" or "benchmarks/counter.BenchmarkIntCounter_increment.func1"
+	Name         string        // Declaration the function belongs to (see SourceLocation.Name)
+	Instructions []Instruction // Instructions in address order
+}
+
+// Instruction is a single machine instruction and the source line it was
+// compiled from.
+type Instruction struct {
+	File    string // Base name of the source file
+	Line    int    // Source line
+	Address string // Address in the test binary, e.g. "0x4f2a20" (jump targets refer to these)
+	Op      string // Instruction in Go assembler syntax, e.g. "LOCK XADDQ AX, 0(AX)"
+}
+
+// Profile summarises a pprof profile collected for one behavior of a benchmark.
+type Profile struct {
+	Kind            string            // Profile kind: "cpu", "mem", "block" or "mutex"
+	Variation       string            // Name of the variation (behavior) the profile was collected for
+	Labels          map[string]string `json:",omitempty"` // Labels of the run matrix cell the profile was collected with; only the first cell is profiled
+	File            string            // Profile file relative to the group directory (slash-separated)
+	FlameGraph      string            `json:",omitempty"` // Flame graph SVG relative to the group directory, if rendered
+	SampleType      string            // Sample type the summary is based on, e.g. "cpu" or "alloc_space"
+	Unit            string            // Unit of all values, e.g. "nanoseconds" or "bytes"
+	Total           int64             // Sum of all samples
+	Functions       []ProfileFunction // Functions with the highest flat or cumulative values
+	AllocationSites []ProfileFunction `json:",omitempty"` // Source lines that allocated the most bytes (memory profiles only)
+}
+
+// ProfileFunction is a function, or a single line of it, in a profile summary.
+type ProfileFunction struct {
+	Name string // Fully qualified function name, e.g. "benchmarks/counter.(*IntCounter).increment"
+	File string `json:",omitempty"` // Source file of the function
+	Line int    `json:",omitempty"` // Source line (allocation sites only)
+	Flat int64  // Value of samples in the function itself
+	Cum  int64  // Value of samples in the function and its callees
+}
+
+// VariantResult compares a behavior built with a build variant to the same
+// behavior built without it.
+type VariantResult struct {
+	Variant         string            // Name of the build variant
+	Variation       string            // Name of the variation (behavior)
+	CPUCount        int               // Number of CPU cores used
+	GoVersion       string            `json:",omitempty"` // Toolchain the comparison was made with
+	Labels          map[string]string `json:",omitempty"` // Other dimensions the comparison was made under
+	Baseline        string            `json:",omitempty"` // Build variant the variant is compared with, e.g. "v3" for "v3+pgo" (empty for the baseline build)
+	NsPerOpBaseline float64           // Median ns/op of the build compared with
+	NsPerOp         float64           // Median ns/op with the variant
+	Change          float64           // Relative change of ns/op, e.g. -0.25 for 25% faster
+	FastestBaseline bool              // Whether the behavior is the fastest of the build compared with
+	Fastest         bool              // Whether the behavior is the fastest with the variant
+}
+
+// GoVersionChange records that the performance of a behavior changed
+// between two consecutive Go versions.
+type GoVersionChange struct {
+	Variation   string            // Name of the variation (behavior)
+	CPUCount    int               // Number of CPU cores used
+	Labels      map[string]string `json:",omitempty"` // Other dimensions the comparison was made under
+	From        string            // Previous Go version
+	To          string            // Go version the change appeared in
+	NsPerOpFrom float64           // Median ns/op with From
+	NsPerOpTo   float64           // Median ns/op with To
+	Change      float64           // Relative change of ns/op, e.g. -0.25 for 25% faster
+}
+
+// Dependency is a module required by the group's go.mod.
+type Dependency struct {
+	Path    string // Module path
+	Version string // Required version
+	Replace string `json:",omitempty"` // Replacement module path (with "@version") or local directory, if replaced
+}
+
+// SourceFile is a single Go file of a benchmark group.
+type SourceFile struct {
+	Name string // File name relative to the group directory (slash-separated)
+	Code string // Unmodified file content
+}
+
+// SourceLocation records where an extracted declaration is defined.
+type SourceLocation struct {
+	Name      string // Declaration name, e.g. "IntCounter", "(*IntCounter).increment"
+	Package   string `json:",omitempty"` // Import path of the shared helper package the declaration was inlined from
+	File      string // File name relative to the group directory, or to the helper package directory (slash-separated)
+	StartLine int    // First line of the declaration, including its doc comment
+	EndLine   int    // Last line of the declaration
 }
 
 type Variation struct {
 	parse.Benchmark
-	Name      string  // Name of the variation
-	CPUCount  int     // Number of CPU cores used
-	OpsPerSec float64 // Performance of the benchmark compared to the fastest benchmark
+	Name              string            // Name of the variation
+	CPUCount          int               // Number of CPU cores used
+	GoVersion         string            `json:",omitempty"` // Toolchain the variation was run with, if run with several
+	Variant           string            `json:",omitempty"` // Build variant the variation was run with (empty for the baseline build)
+	Labels            map[string]string `json:",omitempty"` // Extra dimensions the variation was recorded under, e.g. {"dep/github.com/x/y": "v1.2.0"}
+	OpsPerSec         float64           // Performance of the benchmark compared to the fastest benchmark
+	Runs              int               `json:",omitempty"` // Number of runs the median was taken over
+	MedianCI          float64           `json:",omitempty"` // Relative width of the 95% confidence interval of the median ns/op (0 if too few runs)
+	NormalizedNsPerOp float64           `json:",omitempty"` // ns/op divided by the machine score (generate --normalize)
+}
+
+// RunRecord describes how the results of a group were collected. It is
+// written by "run" next to the benchmark output.
+type RunRecord struct {
+	Adaptive    bool              // Whether runs were repeated until the results were stable
+	Runs        int               // Number of runs
+	TargetCI    float64           `json:",omitempty"` // Relative confidence interval width adaptive runs aimed for
+	StopReason  string            `json:",omitempty"` // Why adaptive runs stopped: "stable", "max-count" or "max-time"
+	MedianCI    float64           `json:",omitempty"` // Widest relative confidence interval of any result (0 if too few runs)
+	Order       string            // Order benchmarks were run in: "sequential" or "interleaved"
+	Seed        int64             `json:",omitempty"` // Seed of the interleaved order
+	Seconds     float64           // Wall time of the runs
+	Conditions  []RunConditions   `json:",omitempty"` // Readings of the machine at the start of each run
+	Noise       *NoiseRecord      `json:",omitempty"` // Noise sentinel readings, if enabled
+	Calibration *Calibration      `json:",omitempty"` // Calibration of the machine, if calibrated
+	Sources     map[string]string `json:",omitempty"` // SHA-256 of every source file the results depend on at the time of the run, by path relative to the group
+}
+
+// RunConditions are the readings of the machine at the start of a run, to
+// correlate anomalies in the results with heat or background load.
+type RunConditions struct {
+	Run              int     // Number of the run, starting at 1
+	Temperature      float64 `json:",omitempty"` // Highest thermal zone temperature in °C
+	Load             float64 `json:",omitempty"` // 1-minute load average per CPU
+	Cooldown         float64 `json:",omitempty"` // Seconds waited for the machine to cool down before the run
+	CooldownTimedOut bool    `json:",omitempty"` // Whether the run started before the machine had cooled down
+	Sentinel         float64 `json:",omitempty"` // ns per iteration of the noise sentinel before the run
+	Deviation        float64 `json:",omitempty"` // Largest relative deviation of the sentinel from its baseline before or after the run
+	Noisy            bool    `json:",omitempty"` // Whether the deviation exceeded the threshold
+	Discarded        bool    `json:",omitempty"` // Whether the results of the noisy run were dropped
+}
+
+// Calibration is the result of the calibration suite on a machine. Dividing
+// ns/op by Score puts results of different machines on a common scale.
+type Calibration struct {
+	GoOS      string
+	GoArch    string
+	CPU       string `json:",omitempty"`
+	NumCPU    int
+	GoVersion string              // Toolchain the suite was built with
+	Date      string              // When the machine was calibrated (RFC 3339)
+	Score     float64             // Geometric mean of the suite's ns/op; lower is faster
+	Results   []CalibrationResult // Result of each benchmark of the suite
+}
+
+// CalibrationResult is the result of a benchmark of the calibration suite.
+type CalibrationResult struct {
+	Name    string  // "alu", "memory", "alloc" or "atomic"
+	NsPerOp float64 // Median ns/op
+}
+
+// NoiseRecord summarises the noise sentinel readings taken while a group ran.
+// Scores are coefficients of variation of the readings: 0 on a perfectly
+// quiet machine.
+type NoiseRecord struct {
+	Threshold     float64 // Relative deviation from the baseline above which runs are noisy
+	Baseline      float64 // Median ns per iteration of the sentinel over the session
+	Score         float64 // Noise score of the readings taken while the group ran
+	SessionScore  float64 // Noise score of all readings of the session so far
+	NoisyRuns     int     `json:",omitempty"` // Runs taken while the sentinel deviated beyond the threshold
+	DiscardedRuns int     `json:",omitempty"` // Noisy runs whose results were dropped
 }
 
 // --- BenchmarkMeta Model ---
@@ -48,4 +336,4 @@ type BenchmarkMeta struct {
 		Implementation string `json:"implementation"`
 		Description    string `json:"description"`
 	} `json:"meta"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+)
+
+// groupModule describes the Go module a benchmark group is built in.
+type groupModule struct {
+	path     string                // Module path
+	requires map[string]Dependency // Required modules by module path, with replacements applied
+}
+
+// loadGroupModule reads the go.mod that applies to groupDir: the group's own
+// go.mod if it has one, otherwise the nearest one up to benchmarksDir.
+// If the group has a go.work file, the modules it uses are added as
+// dependencies replaced by their local directory.
+func loadGroupModule(groupDir, benchmarksDir string) (*groupModule, error) {
+	dir := groupDir
+	for {
+		data, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err == nil {
+			mod, err := parseGroupModule(filepath.Join(dir, "go.mod"), data)
+			if err != nil {
+				return nil, err
+			}
+			if err := mod.addWorkspace(groupDir); err != nil {
+				return nil, err
+			}
+			return mod, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read go.mod: %w", err)
+		}
+
+		if rel, err := filepath.Rel(benchmarksDir, dir); err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			return nil, nil
+		}
+		dir = filepath.Dir(dir)
+	}
+}
+
+func parseGroupModule(name string, data []byte) (*groupModule, error) {
+	f, err := modfile.Parse(name, data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse go.mod: %w", err)
+	}
+
+	mod := &groupModule{requires: make(map[string]Dependency)}
+	if f.Module != nil {
+		mod.path = f.Module.Mod.Path
+	}
+
+	for _, r := range f.Require {
+		mod.requires[r.Mod.Path] = Dependency{Path: r.Mod.Path, Version: r.Mod.Version}
+	}
+
+	for _, r := range f.Replace {
+		dep, ok := mod.requires[r.Old.Path]
+		if !ok || (r.Old.Version != "" && r.Old.Version != dep.Version) {
+			continue
+		}
+
+		dep.Replace = r.New.Path
+		if r.New.Version != "" {
+			dep.Replace += "@" + r.New.Version
+		}
+		mod.requires[r.Old.Path] = dep
+	}
+
+	return mod, nil
+}
+
+// addWorkspace adds the modules used by groupDir/go.work, if present.
+func (m *groupModule) addWorkspace(groupDir string) error {
+	name := filepath.Join(groupDir, "go.work")
+	data, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read go.work: %w", err)
+	}
+
+	work, err := modfile.ParseWork(name, data, nil)
+	if err != nil {
+		return fmt.Errorf("failed to parse go.work: %w", err)
+	}
+
+	for _, use := range work.Use {
+		modData, err := os.ReadFile(filepath.Join(groupDir, filepath.FromSlash(use.Path), "go.mod"))
+		if err != nil {
+			return fmt.Errorf("failed to read workspace module %s: %w", use.Path, err)
+		}
+
+		modPath := modfile.ModulePath(modData)
+		if modPath == "" || modPath == m.path {
+			continue
+		}
+		m.requires[modPath] = Dependency{Path: modPath, Replace: use.Path}
+	}
+
+	return nil
+}
+
+// dependencies returns the required modules that provide the given import
+// paths, sorted by module path. Standard library and module-local imports
+// are not dependencies.
+func (m *groupModule) dependencies(importPaths []string) []Dependency {
+	found := make(map[string]bool)
+	var deps []Dependency
+	for _, importPath := range importPaths {
+		// The module providing a package is the one with the longest matching path.
+		var best string
+		for modPath := range m.requires {
+			if (importPath == modPath || strings.HasPrefix(importPath, modPath+"/")) && len(modPath) > len(best) {
+				best = modPath
+			}
+		}
+
+		if best != "" && !found[best] {
+			found[best] = true
+			deps = append(deps, m.requires[best])
+		}
+	}
+
+	sort.Slice(deps, func(i, j int) bool {
+		return deps[i].Path < deps[j].Path
+	})
+
+	return deps
+}
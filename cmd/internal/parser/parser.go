@@ -3,10 +3,13 @@ package parser
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"fmt"
 	"go/token"
+	"io"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -62,30 +65,175 @@ func parseSystemInfo(path string) (SystemInfo, error) {
 	return info, scanner.Err()
 }
 
+// LabelPrefix marks configuration lines in benchmark output that label the
+// results following them, e.g. "gobench-go: go1.24.0". A block of consecutive
+// label lines replaces the labels of the previous block.
+const LabelPrefix = "gobench-"
+
+// BaselineVariant is the value of the "variant" label of results built
+// without a build variant when the group also ran with variants. The label
+// keeps them from inheriting the labels of the preceding variant; it is
+// parsed back to an empty Variant.
+const BaselineVariant = "baseline"
+
+// labelledBenchmark is a single benchmark result with the labels it was
+// recorded under.
+type labelledBenchmark struct {
+	*parse.Benchmark
+	labels map[string]string
+}
+
+// parseBenchOutput extracts all benchmark results from testing.B output,
+// in order of appearance, together with their labels.
+func parseBenchOutput(r io.Reader) ([]labelledBenchmark, error) {
+	var results []labelledBenchmark
+	var labels map[string]string
+	inLabelBlock := false
+	ord := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if strings.HasPrefix(line, LabelPrefix) {
+			key, value, ok := strings.Cut(strings.TrimPrefix(line, LabelPrefix), ":")
+			if !ok {
+				continue
+			}
+			if !inLabelBlock {
+				labels = make(map[string]string)
+				inLabelBlock = true
+			}
+			labels[key] = strings.TrimSpace(value)
+			continue
+		}
+		inLabelBlock = false
+
+		if b, err := parse.ParseLine(line); err == nil {
+			b.Ord = ord
+			ord++
+			results = append(results, labelledBenchmark{Benchmark: b, labels: labels})
+		}
+	}
+
+	return results, scanner.Err()
+}
+
+// parseBenchFile parses a benchmark output file into its system info and
+// variations.
+func parseBenchFile(logger *slog.Logger, path string) (SystemInfo, []Variation, error) {
+	// Parse system info from the header
+	sysInfo, err := parseSystemInfo(path)
+	if err != nil {
+		return SystemInfo{}, nil, fmt.Errorf("failed to parse system info: %w", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return SystemInfo{}, nil, fmt.Errorf("failed to open benchmarkGroup file: %w", err)
+	}
+	defer f.Close()
+
+	benchResults, err := parseBenchOutput(f)
+	if err != nil {
+		return SystemInfo{}, nil, fmt.Errorf("failed to parse benchmarkGroup file: %w", err)
+	}
+
+	return sysInfo, benchVariations(logger, benchResults), nil
+}
+
+// benchVariations turns benchmark results into variations, splitting the
+// benchmark name into implementation, behavior and CPU count.
+func benchVariations(logger *slog.Logger, results []labelledBenchmark) []Variation {
+	var variations []Variation
+	for _, result := range results {
+		s := result.Name
+		logger.Debug("adding variation", "name", s)
+		variation := Variation{
+			Benchmark: *result.Benchmark,
+			Labels:    result.labels,
+		}
+
+		// The toolchain and build variant are first-class dimensions rather than labels.
+		variation.GoVersion = takeLabel(&variation.Labels, "go")
+		variation.Variant = takeLabel(&variation.Labels, "variant")
+		if variation.Variant == BaselineVariant {
+			variation.Variant = ""
+		}
+
+		brNameParts := strings.Split(variation.Benchmark.Name, "_") // "BenchmarkName_VariationName" -> ["BenchmarkName", "VariationName"]
+		logger.Debug("benchmark name parts", "parts", brNameParts)
+		variation.Benchmark.Name = brNameParts[0] // Benchmark name is the first part.
+
+		// If there are more parts, then the variation name is the second part.
+		if len(brNameParts) > 1 {
+			variation.Name = brNameParts[1]
+			variation.Name = strings.ReplaceAll(variation.Name, "_", " ")
+			variation.Name = strings.ReplaceAll(variation.Name, "-", " ")
+
+			// Variation parts.
+			brVariationParts := strings.Split(variation.Name, " ")
+			logger.Debug("benchmark variation name parts", "parts", brVariationParts)
+			variation.Name = strings.Join(brVariationParts[:len(brVariationParts)-1], " ")
+
+			// The last part is the CPU count, if it exists.
+			var err error
+			variation.CPUCount, err = strconv.Atoi(brVariationParts[len(brVariationParts)-1])
+			if err != nil {
+				variation.CPUCount = 1
+				variation.Name = strings.Join(brVariationParts, " ")
+			}
+		}
+
+		// Split name. "BenchmarkName" -> "BenchmarkGroup Name". Split happens at every uppercase letter.
+		variation.Benchmark.Name = strings.Join(utils.SplitCamelCase(variation.Benchmark.Name)[1:], " ")
+		logger.Debug("adding benchmark variation", "benchmark name", variation.Benchmark.Name, "variation name", variation.Name, "cpuCount", variation.CPUCount, "orig name", s)
+
+		// Calculate ops per second by dividing ns/op by 1e9.
+		variation.OpsPerSec = 1e9 / variation.NsPerOp
+
+		variations = append(variations, variation)
+	}
+
+	return variations
+}
+
 // processSingleGroup processes a single benchmark directory and returns the
 // resulting BenchmarkGroup. It is extracted so that errors can be handled
 // per-group without aborting the entire walk.
-func processSingleGroup(logger *slog.Logger, path string) (BenchmarkGroup, error) {
+// helpers is nil when shared helpers should not be inlined.
+func processSingleGroup(logger *slog.Logger, benchmarksDir, path string, helpers *helperLoader) (BenchmarkGroup, error) {
 	var benchmarkGroup BenchmarkGroup
 	benchmarkGroup.Dir = path
 
-	benchOutPath := path + string(os.PathSeparator) + "_bench.out"
-
-	// Parse system info from the header
-	sysInfo, err := parseSystemInfo(benchOutPath)
+	module, err := loadGroupModule(path, benchmarksDir)
 	if err != nil {
-		return BenchmarkGroup{}, fmt.Errorf("failed to parse system info: %w", err)
+		return BenchmarkGroup{}, fmt.Errorf("failed to load group module: %w", err)
+	}
+	if module != nil {
+		benchmarkGroup.Module = module.path
 	}
-	benchmarkGroup.System = sysInfo
 
-	f, err := os.Open(benchOutPath)
+	benchmarkGroup.Machines, err = parseMachineFiles(logger, path)
 	if err != nil {
-		return BenchmarkGroup{}, fmt.Errorf("failed to open benchmarkGroup file: %w", err)
+		return BenchmarkGroup{}, err
 	}
 
-	set, err := parse.ParseSet(f)
-	if err != nil {
-		return BenchmarkGroup{}, fmt.Errorf("failed to parse benchmarkGroup file: %w", err)
+	// A group without its own results (_bench.out is not checked in) is
+	// still shown with the results merged from other machines.
+	benchOutPath := path + string(os.PathSeparator) + "_bench.out"
+
+	var variations []Variation
+	if _, err := os.Stat(benchOutPath); err == nil || len(benchmarkGroup.Machines) == 0 {
+		var sysInfo SystemInfo
+		sysInfo, variations, err = parseBenchFile(logger, benchOutPath)
+		if err != nil {
+			return BenchmarkGroup{}, err
+		}
+		benchmarkGroup.System = sysInfo
+		benchmarkGroup.Machine = MachineFingerprint(sysInfo)
+	} else {
+		logger.Debug("no local results, using merged machines only", "path", path, "machines", len(benchmarkGroup.Machines))
 	}
 
 	// Init BenchmarkMeta
@@ -112,99 +260,78 @@ func processSingleGroup(logger *slog.Logger, path string) (BenchmarkGroup, error
 		logger.Warn("no meta file found", "path", metaFilePath)
 	}
 
-	// Get all *_test.go files
-	err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
-		if strings.HasSuffix(path, ".go") {
-			logger.Debug("found test file", "path", path)
-
-			// Read test file
-			b, err := os.ReadFile(path)
-			if err != nil {
-				return fmt.Errorf("failed to read test file: %w", err)
-			}
+	// Get all *.go files of the group. Subdirectories are separate packages
+	// (helpers, testdata or nested groups) and are not part of this group.
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return BenchmarkGroup{}, fmt.Errorf("failed to read group directory: %w", err)
+	}
 
-			cC, err := cleanCode(string(b))
-			if err != nil {
-				return fmt.Errorf("failed to clean test file: %w", err)
-			}
+	sources := newSourceIndex()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") {
+			continue
+		}
 
-			benchmarkGroup.Code += cC
+		filePath := filepath.Join(path, entry.Name())
+		logger.Debug("found test file", "path", filePath)
 
-			consts, err := getConsts(string(b))
-			if err != nil {
-				return fmt.Errorf("failed to get consts: %w", err)
-			}
-
-			benchmarkGroup.Constants += consts
+		// Read test file
+		b, err := os.ReadFile(filePath)
+		if err != nil {
+			return BenchmarkGroup{}, fmt.Errorf("failed to read test file: %w", err)
 		}
 
-		return nil
-	})
-	if err != nil {
-		return BenchmarkGroup{}, fmt.Errorf("failed to walk test files: %w", err)
-	}
+		benchmarkGroup.Files = append(benchmarkGroup.Files, SourceFile{Name: entry.Name(), Code: string(b)})
 
-	benchmarkGroup.Code = strings.TrimSpace(benchmarkGroup.Code)
+		if err := sources.add(entry.Name(), b); err != nil {
+			return BenchmarkGroup{}, fmt.Errorf("failed to parse test file: %w", err)
+		}
 
-	benchmarkGroup.Name = meta.Name
-	benchmarkGroup.Description = meta.Description
-	benchmarkGroup.Headline = meta.Headline
+		cC, err := cleanCode(string(b))
+		if err != nil {
+			return BenchmarkGroup{}, fmt.Errorf("failed to clean test file: %w", err)
+		}
 
-	var variations []Variation
-	for s, i := range set {
-		for _, b := range i {
-			logger.Debug("adding variation", "name", s)
-			variation := Variation{
-				Benchmark: *b,
-			}
+		benchmarkGroup.Code += cC
 
-			brNameParts := strings.Split(variation.Benchmark.Name, "_") // "BenchmarkName_VariationName" -> ["BenchmarkName", "VariationName"]
-			logger.Debug("benchmark name parts", "parts", brNameParts)
-			variation.Benchmark.Name = brNameParts[0] // Benchmark name is the first part.
-
-			// If there are more parts, then the variation name is the second part.
-			if len(brNameParts) > 1 {
-				variation.Name = brNameParts[1]
-				variation.Name = strings.ReplaceAll(variation.Name, "_", " ")
-				variation.Name = strings.ReplaceAll(variation.Name, "-", " ")
-
-				// Variation parts.
-				brVariationParts := strings.Split(variation.Name, " ")
-				logger.Debug("benchmark variation name parts", "parts", brVariationParts)
-				variation.Name = strings.Join(brVariationParts[:len(brVariationParts)-1], " ")
-
-				// The last part is the CPU count, if it exists.
-				variation.CPUCount, err = strconv.Atoi(brVariationParts[len(brVariationParts)-1])
-				if err != nil {
-					variation.CPUCount = 1
-					variation.Name = strings.Join(brVariationParts, " ")
-				}
-			}
+		consts, err := getConsts(string(b))
+		if err != nil {
+			return BenchmarkGroup{}, fmt.Errorf("failed to get consts: %w", err)
+		}
 
-			// Split name. "BenchmarkName" -> "BenchmarkGroup Name". Split happens at every uppercase letter.
-			variation.Benchmark.Name = strings.Join(utils.SplitCamelCase(variation.Benchmark.Name)[1:], " ")
-			logger.Debug("adding benchmark variation", "benchmark name", variation.Benchmark.Name, "variation name", variation.Name, "cpuCount", variation.CPUCount, "orig name", s)
+		benchmarkGroup.Constants += consts
+	}
 
-			// Calculate ops per second by dividing ns/op by 1e9.
-			variation.OpsPerSec = 1e9 / variation.NsPerOp
+	benchmarkGroup.Code = strings.TrimSpace(benchmarkGroup.Code)
 
-			variations = append(variations, variation)
-		}
-	}
+	// Doc comments are used as fallbacks; _meta.yml always takes precedence.
+	benchmarkGroup.Name = meta.Name
+	benchmarkGroup.Description = cmp.Or(meta.Description, sources.packageDoc)
+	benchmarkGroup.Headline = cmp.Or(meta.Headline, sources.packageSynopsis())
 
 	benchmarks := make(map[string][]Variation)
 	for _, v := range variations {
 		benchmarks[v.Benchmark.Name] = append(benchmarks[v.Benchmark.Name], v)
 	}
+	// Implementations only measured on other machines have no local variations
+	for _, machine := range benchmarkGroup.Machines {
+		for _, bench := range machine.Benchmarks {
+			if _, ok := benchmarks[bench.Name]; !ok {
+				benchmarks[bench.Name] = nil
+			}
+		}
+	}
 
 	var results []Benchmark
 	for name, variations := range benchmarks {
 		var benchmark Benchmark
 		benchmark.Name = name
 		benchmark.Variations = variations
+		benchmark.Description = sources.implementationDoc(strings.ReplaceAll(name, " ", ""))
 
 		for _, m := range meta.Meta {
-			if m.Implementation == name {
+			if m.Implementation == name && m.Description != "" {
 				benchmark.Description = m.Description
 			}
 		}
@@ -223,6 +350,28 @@ func processSingleGroup(logger *slog.Logger, path string) (BenchmarkGroup, error
 
 		benchmark.Code = strings.TrimSpace(benchmark.Code)
 		benchmark.BenchmarkCode = strings.TrimSpace(benchmark.BenchmarkCode)
+		benchmark.Sources = sources.benchmarkSources(strings.ReplaceAll(name, " ", ""))
+
+		decls := benchmarkDecls(sources.file, strings.ReplaceAll(name, " ", ""))
+
+		if module != nil {
+			var importPaths []string
+			for _, sel := range sources.importedSelectors(decls) {
+				importPaths = append(importPaths, sel.importPath)
+			}
+			benchmark.Dependencies = module.dependencies(importPaths)
+		}
+
+		if helpers != nil {
+			logger.Debug("inlining shared helpers", "benchmark name", name)
+			helperCode, helperSources, err := helpers.inline(sources, decls)
+			if err != nil {
+				return BenchmarkGroup{}, fmt.Errorf("failed to inline shared helpers: %w", err)
+			}
+
+			benchmark.BenchmarkCode = strings.TrimSpace(helperCode + benchmark.BenchmarkCode)
+			benchmark.Sources = append(helperSources, benchmark.Sources...)
+		}
 
 		results = append(results, benchmark)
 	}
@@ -237,18 +386,44 @@ func processSingleGroup(logger *slog.Logger, path string) (BenchmarkGroup, error
 	return benchmarkGroup, nil
 }
 
-func ProcessBenchmarkGroups(logger *slog.Logger, benchmarksDir string) ([]BenchmarkGroup, error) {
+// ProcessOptions configures how benchmark groups are processed.
+type ProcessOptions struct {
+	// InlineHelpers adds declarations from shared helper packages
+	// (benchmarks/internal/...) that a benchmark references to its
+	// BenchmarkCode, each preceded by a comment naming its origin.
+	InlineHelpers bool
+}
+
+func ProcessBenchmarkGroups(logger *slog.Logger, benchmarksDir string, opts ProcessOptions) ([]BenchmarkGroup, error) {
 	var groups []BenchmarkGroup
 
+	var helpers *helperLoader
+	if opts.InlineHelpers {
+		var err error
+		helpers, err = newHelperLoader(benchmarksDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up shared helpers: %w", err)
+		}
+	}
+
 	err := utils.WalkOverBenchmarks(benchmarksDir, func(path string) error {
 		logger.Debug("walking through benchmarks", "currentPath", path)
 
-		group, err := processSingleGroup(logger, path)
+		group, err := processSingleGroup(logger, benchmarksDir, path, helpers)
 		if err != nil {
 			logger.Error("skipping benchmark group", "path", path, "error", err)
 			return nil
 		}
 
+		rel, err := filepath.Rel(benchmarksDir, path)
+		if err != nil {
+			return fmt.Errorf("failed to get relative group path: %w", err)
+		}
+		group.Path = filepath.ToSlash(rel)
+		if category := filepath.ToSlash(filepath.Dir(rel)); category != "." {
+			group.Category = category
+		}
+
 		groups = append(groups, group)
 		return nil
 	})
@@ -329,6 +504,18 @@ func getBenchmarkCode(src, name string) (string, error) {
 		return "", fmt.Errorf("parsed file is nil")
 	}
 
+	newFile := &dst.File{Name: dst.NewIdent("dummy")}
+	newFile.Decls = benchmarkDecls(file, name)
+
+	var buf bytes.Buffer
+	decorator.Fprint(&buf, newFile)
+	return cleanCode(buf.String())
+}
+
+// benchmarkDecls returns the declarations that make up the benchmark code of
+// an implementation: referenced types and their methods, referenced helper
+// functions and finally the benchmark functions themselves.
+func benchmarkDecls(file *dst.File, name string) []dst.Decl {
 	// Index all locally-defined type names and standalone function names.
 	typeNames := make(map[string]bool)
 	funcNames := make(map[string]bool)
@@ -384,7 +571,7 @@ func getBenchmarkCode(src, name string) (string, error) {
 
 	// Build output: referenced types + their methods, then helper functions,
 	// then benchmark functions. Source order is preserved within each group.
-	newFile := &dst.File{Name: dst.NewIdent("dummy")}
+	var decls []dst.Decl
 	added := make(map[dst.Decl]bool)
 
 	for _, decl := range file.Decls {
@@ -394,7 +581,7 @@ func getBenchmarkCode(src, name string) (string, error) {
 				for _, spec := range d.Specs {
 					if ts, ok := spec.(*dst.TypeSpec); ok && refTypes[ts.Name.Name] {
 						if !added[decl] {
-							newFile.Decls = append(newFile.Decls, decl)
+							decls = append(decls, decl)
 							added[decl] = true
 						}
 					}
@@ -404,26 +591,20 @@ func getBenchmarkCode(src, name string) (string, error) {
 			// Methods on referenced types.
 			if d.Recv != nil && len(d.Recv.List) > 0 {
 				if refTypes[recvTypeName(d)] && !added[decl] {
-					newFile.Decls = append(newFile.Decls, decl)
+					decls = append(decls, decl)
 					added[decl] = true
 				}
 			}
 			// Referenced standalone helper functions.
 			if d.Recv == nil && refFuncs[d.Name.Name] && !added[decl] {
-				newFile.Decls = append(newFile.Decls, decl)
+				decls = append(decls, decl)
 				added[decl] = true
 			}
 		}
 	}
 
 	// Append benchmark functions last.
-	for _, fn := range benchFuncs {
-		newFile.Decls = append(newFile.Decls, fn)
-	}
-
-	var buf bytes.Buffer
-	decorator.Fprint(&buf, newFile)
-	return cleanCode(buf.String())
+	return append(decls, benchFuncs...)
 }
 
 func getCode(src, name string) (string, error) {
@@ -465,3 +646,19 @@ func getCode(src, name string) (string, error) {
 	decorator.Fprint(&buf, newFile)
 	return cleanCode(buf.String())
 }
+
+// takeLabel removes key from labels and returns its value. The map is
+// cloned before modification since labels are shared between variations.
+func takeLabel(labels *map[string]string, key string) string {
+	value, ok := (*labels)[key]
+	if !ok {
+		return ""
+	}
+
+	*labels = maps.Clone(*labels)
+	delete(*labels, key)
+	if len(*labels) == 0 {
+		*labels = nil
+	}
+	return value
+}
@@ -1,6 +1,9 @@
 package parser
 
 import (
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -246,3 +249,499 @@ func BenchmarkQuickSort_sort(b *testing.B) {
 
 	t.Logf("output:\n%s", got)
 }
+
+func TestSourceIndex_benchmarkSources(t *testing.T) {
+	counter := `package counter
+
+import "testing"
+
+// IntCounter is a plain counter.
+type IntCounter struct {
+	count uint64
+}
+
+func (c *IntCounter) increment() {
+	c.count++
+}
+
+func BenchmarkIntCounter_increment(b *testing.B) {
+	var counter IntCounter
+	for i := 0; i < b.N; i++ {
+		counter.increment()
+	}
+}
+`
+	consts := `package counter
+
+const size = 100
+`
+
+	idx := newSourceIndex()
+	if err := idx.add("a-consts.go", []byte(consts)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := idx.add("counter_test.go", []byte(counter)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	got := idx.benchmarkSources("IntCounter")
+	want := []SourceLocation{
+		{Name: "IntCounter", File: "counter_test.go", StartLine: 5, EndLine: 8},
+		{Name: "(*IntCounter).increment", File: "counter_test.go", StartLine: 10, EndLine: 12},
+		{Name: "BenchmarkIntCounter_increment", File: "counter_test.go", StartLine: 14, EndLine: 19},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d sources, got %d: %+v", len(want), len(got), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("source %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+func TestSourceIndex_docComments(t *testing.T) {
+	src := `// Package printing compares fmt printing functions. It writes to stdout.
+package printing
+
+import "testing"
+
+// Print writes to stdout.
+func BenchmarkPrint_run(b *testing.B) {}
+
+func BenchmarkPrintln_run(b *testing.B) {}
+
+// Println writes to stdout with a trailing newline.
+func BenchmarkPrintln_other(b *testing.B) {}
+
+// Fprint writes to an io.Writer.
+type Fprint struct{}
+
+// Fprint benchmark.
+func BenchmarkFprint_run(b *testing.B) {}
+`
+
+	idx := newSourceIndex()
+	if err := idx.add("printing_test.go", []byte(src)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, want := idx.packageDoc, "Package printing compares fmt printing functions. It writes to stdout."; got != want {
+		t.Errorf("expected package doc %q, got %q", want, got)
+	}
+	if got, want := idx.packageSynopsis(), "Package printing compares fmt printing functions."; got != want {
+		t.Errorf("expected synopsis %q, got %q", want, got)
+	}
+
+	tests := map[string]string{
+		"Print":   "Print writes to stdout.",
+		"Println": "Println writes to stdout with a trailing newline.",
+		"Fprint":  "Fprint writes to an io.Writer.",
+		"Printf":  "",
+	}
+	for name, want := range tests {
+		if got := idx.implementationDoc(name); got != want {
+			t.Errorf("%s: expected doc %q, got %q", name, want, got)
+		}
+	}
+}
+
+func TestHelperLoader_inline(t *testing.T) {
+	base := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module benchmarks\n\ngo 1.25\n",
+		"internal/random/random.go": `package random
+
+import "math/rand"
+
+// seed keeps generated data reproducible.
+const seed = 42
+
+// Ints returns n pseudo-random ints.
+func Ints(n int) []int {
+	r := rand.New(rand.NewSource(seed))
+	return r.Perm(n)
+}
+
+// Unused is not referenced by any benchmark.
+func Unused() {}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := `package sorting
+
+import (
+	"sort"
+	"testing"
+
+	"benchmarks/internal/random"
+)
+
+func BenchmarkBuiltinSort_sort(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		sort.Ints(random.Ints(1000))
+	}
+}
+`
+
+	idx := newSourceIndex()
+	if err := idx.add("sort_test.go", []byte(src)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	helpers, err := newHelperLoader(base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code, sources, err := helpers.inline(idx, benchmarkDecls(idx.file, "BuiltinSort"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(code, "// From benchmarks/internal/random (random.go)") {
+		t.Error("expected origin note before inlined helpers")
+	}
+	if !strings.Contains(code, "func Ints(n int) []int") {
+		t.Error("expected referenced helper in output")
+	}
+	if !strings.Contains(code, "const seed = 42") {
+		t.Error("expected transitive helper dependency in output")
+	}
+	if strings.Contains(code, "Unused") {
+		t.Error("should not include unreferenced helpers")
+	}
+
+	if len(sources) != 2 {
+		t.Fatalf("expected 2 sources, got %d: %+v", len(sources), sources)
+	}
+	for _, s := range sources {
+		if s.Package != "benchmarks/internal/random" || s.File != "random.go" {
+			t.Errorf("unexpected source location %+v", s)
+		}
+	}
+
+	t.Logf("output:\n%s", code)
+}
+
+func TestHelperLoader_inlineAcrossPackages(t *testing.T) {
+	base := t.TempDir()
+	files := map[string]string{
+		"go.mod": "module benchmarks\n\ngo 1.25\n",
+		"internal/data/data.go": `package data
+
+import "benchmarks/internal/random"
+
+// Sorted returns n ints in ascending order.
+func Sorted(n int) []int {
+	s := random.New(n).Ints()
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// New is not referenced: random.New above belongs to another package.
+func New() {}
+`,
+		"internal/random/random.go": `package random
+
+// Source generates pseudo-random data.
+type Source struct{ n int }
+
+// New returns a source of n values.
+func New(n int) *Source { return &Source{n: n} }
+
+// Ints returns the values of the source.
+func (s *Source) Ints() []int { return make([]int, s.n) }
+
+// Ints is not referenced: s.Ints above selects a method.
+func Ints() {}
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	src := `package sorting
+
+import (
+	"sort"
+	"testing"
+
+	"benchmarks/internal/data"
+)
+
+func BenchmarkBuiltinSort_sorted(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		sort.Ints(data.Sorted(1000))
+	}
+}
+`
+
+	idx := newSourceIndex()
+	if err := idx.add("sort_test.go", []byte(src)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	helpers, err := newHelperLoader(base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code, sources, err := helpers.inline(idx, benchmarkDecls(idx.file, "BuiltinSort"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, want := range []string{
+		"// From benchmarks/internal/data (data.go)",
+		"func Sorted(n int) []int",
+		"// From benchmarks/internal/random (random.go)",
+		"type Source struct",
+		"func New(n int) *Source",
+		"func (s *Source) Ints() []int",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("expected %q in output", want)
+		}
+	}
+	for _, unwanted := range []string{"func New() {}", "func Ints() {}"} {
+		if strings.Contains(code, unwanted) {
+			t.Errorf("should not include %q, which is only matched by name", unwanted)
+		}
+	}
+
+	packages := make(map[string]int)
+	for _, s := range sources {
+		packages[s.Package]++
+	}
+	if packages["benchmarks/internal/data"] != 1 || packages["benchmarks/internal/random"] != 3 {
+		t.Errorf("unexpected sources %+v", sources)
+	}
+
+	t.Logf("output:\n%s", code)
+}
+
+func TestGroupModule_dependencies(t *testing.T) {
+	gomod := `module benchmarks/json
+
+go 1.25
+
+require (
+	github.com/cespare/xxhash/v2 v2.3.0
+	github.com/goccy/go-json v0.10.5
+	github.com/json-iterator/go v1.1.12
+	github.com/modern-go/reflect2 v1.0.2 // indirect
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+replace github.com/json-iterator/go => ../forks/jsoniter
+`
+	goccy := `package json
+
+import (
+	"testing"
+
+	"github.com/goccy/go-json"
+)
+
+func BenchmarkGoccy_run(b *testing.B) {
+	for b.Loop() {
+		json.Marshal(1)
+	}
+}
+`
+	jsoniter := `package json
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	jsoniter "github.com/json-iterator/go"
+	"gopkg.in/yaml.v3"
+)
+
+func BenchmarkJsoniter_run(b *testing.B) {
+	for b.Loop() {
+		jsoniter.Marshal(1)
+		json.Valid(nil)
+		yaml.Marshal(1)
+		xxhash.Sum64(nil)
+	}
+}
+`
+
+	mod, err := parseGroupModule("go.mod", []byte(gomod))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if mod.path != "benchmarks/json" {
+		t.Errorf("expected module path benchmarks/json, got %q", mod.path)
+	}
+
+	idx := newSourceIndex()
+	if err := idx.add("goccy_test.go", []byte(goccy)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := idx.add("jsoniter_test.go", []byte(jsoniter)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string][]Dependency{
+		"Goccy": {
+			{Path: "github.com/goccy/go-json", Version: "v0.10.5"},
+		},
+		"Jsoniter": {
+			{Path: "github.com/cespare/xxhash/v2", Version: "v2.3.0"},
+			{Path: "github.com/json-iterator/go", Version: "v1.1.12", Replace: "../forks/jsoniter"},
+			{Path: "gopkg.in/yaml.v3", Version: "v3.0.1"},
+		},
+	}
+	for name, want := range tests {
+		var importPaths []string
+		for _, sel := range idx.importedSelectors(benchmarkDecls(idx.file, name)) {
+			importPaths = append(importPaths, sel.importPath)
+		}
+
+		got := mod.dependencies(importPaths)
+		if len(got) != len(want) {
+			t.Fatalf("%s: expected %d dependencies, got %d: %+v", name, len(want), len(got), got)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s: dependency %d: expected %+v, got %+v", name, i, want[i], got[i])
+			}
+		}
+	}
+
+	// Packages below a module path belong to the module.
+	if got := mod.dependencies([]string{"github.com/goccy/go-json/internal/encoder"}); len(got) != 1 || got[0].Path != "github.com/goccy/go-json" {
+		t.Errorf("expected the subpackage to resolve to github.com/goccy/go-json, got %+v", got)
+	}
+}
+
+func TestParseBenchOutput_labels(t *testing.T) {
+	out := `goos: linux
+goarch: amd64
+BenchmarkFoo_run 	1000	10.0 ns/op
+gobench-dep/github.com/x/y: v1.0.0
+gobench-go: go1.24.0
+goos: linux
+BenchmarkFoo_run 	1000	12.0 ns/op
+BenchmarkBar_run 	1000	14.0 ns/op
+gobench-dep/github.com/x/y: v1.1.0
+BenchmarkFoo_run 	1000	11.0 ns/op
+`
+
+	got, err := parseBenchOutput(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got) != 4 {
+		t.Fatalf("expected 4 results, got %d", len(got))
+	}
+
+	if got[0].labels != nil {
+		t.Errorf("expected no labels for first result, got %v", got[0].labels)
+	}
+	for _, i := range []int{1, 2} {
+		if got[i].labels["dep/github.com/x/y"] != "v1.0.0" || got[i].labels["go"] != "go1.24.0" {
+			t.Errorf("result %d: unexpected labels %v", i, got[i].labels)
+		}
+	}
+	// A new label block replaces the previous labels entirely.
+	if len(got[3].labels) != 1 || got[3].labels["dep/github.com/x/y"] != "v1.1.0" {
+		t.Errorf("result 3: unexpected labels %v", got[3].labels)
+	}
+
+	for i, r := range got {
+		if r.Ord != i {
+			t.Errorf("result %d: expected ord %d, got %d", i, i, r.Ord)
+		}
+	}
+}
+
+func TestBenchVariations_baseline(t *testing.T) {
+	// Two runs of a group with a baseline and a "pgo" variant.
+	run := `gobench-variant: baseline
+goos: linux
+BenchmarkFoo_run-1 	1000	10.0 ns/op
+gobench-variant: pgo
+goos: linux
+BenchmarkFoo_run-1 	1000	6.0 ns/op
+`
+	results, err := parseBenchOutput(strings.NewReader(run + run))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	variations := benchVariations(slog.New(slog.DiscardHandler), results)
+	if len(variations) != 4 {
+		t.Fatalf("expected 4 variations, got %d", len(variations))
+	}
+	for i, want := range []string{"", "pgo", "", "pgo"} {
+		v := variations[i]
+		if v.Variant != want || v.Labels != nil {
+			t.Errorf("variation %d: expected variant %q without labels, got %q with %v", i, want, v.Variant, v.Labels)
+		}
+		if wantNs := map[string]float64{"": 10, "pgo": 6}[want]; v.NsPerOp != wantNs {
+			t.Errorf("variation %d: expected %v ns/op, got %v", i, wantNs, v.NsPerOp)
+		}
+	}
+}
+
+func TestProcessSingleGroup_machineFilesOnly(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"_meta.yml":             "name: Group\n",
+		"group_test.go":         "package group\n\nimport \"testing\"\n\nfunc BenchmarkArray_read(b *testing.B) {}\n",
+		MachineBenchFile("abc"): "goos: linux\ngoarch: amd64\ncpu: Remote CPU\nBenchmarkArray_read-8   1000   10 ns/op\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	group, err := processSingleGroup(slog.New(slog.DiscardHandler), dir, dir, nil)
+	if err != nil {
+		t.Fatalf("expected a group with only merged results to be processed: %v", err)
+	}
+	if group.Machine != "" || len(group.Machines) != 1 || group.Machines[0].Machine != "abc" {
+		t.Errorf("expected only the merged machine, got %q and %+v", group.Machine, group.Machines)
+	}
+	if len(group.Benchmarks) != 1 || group.Benchmarks[0].Name != "Array" || len(group.Benchmarks[0].Variations) != 0 {
+		t.Fatalf("expected the implementation without local variations, got %+v", group.Benchmarks)
+	}
+	if group.Benchmarks[0].BenchmarkCode == "" {
+		t.Error("expected the benchmark code of the implementation")
+	}
+
+	// Without any results the group is still skipped.
+	if err := os.Remove(filepath.Join(dir, MachineBenchFile("abc"))); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := processSingleGroup(slog.New(slog.DiscardHandler), dir, dir, nil); err == nil {
+		t.Error("expected an error for a group without results")
+	}
+}
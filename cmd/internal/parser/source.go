@@ -0,0 +1,224 @@
+package parser
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/dave/dst"
+	"github.com/dave/dst/decorator"
+)
+
+// sourceIndex holds the declarations of all Go files of a group, parsed
+// individually so that each declaration keeps its original position.
+type sourceIndex struct {
+	file       *dst.File                    // All declarations of the group in a single file
+	locations  map[dst.Decl]SourceLocation  // Origin of every declaration in file
+	docs       map[dst.Decl]string          // Doc comment text of every documented declaration
+	imports    map[string]map[string]string // Import paths by local package name, per file
+	packageDoc string                       // First package doc comment found in the group
+}
+
+func newSourceIndex() *sourceIndex {
+	return &sourceIndex{
+		file:      &dst.File{Name: dst.NewIdent("dummy")},
+		locations: make(map[dst.Decl]SourceLocation),
+		docs:      make(map[dst.Decl]string),
+		imports:   make(map[string]map[string]string),
+	}
+}
+
+// add parses a single Go file and records the location of its declarations.
+// name is the file name relative to the group directory.
+func (idx *sourceIndex) add(name string, src []byte) error {
+	fset := token.NewFileSet()
+	dec := decorator.NewDecorator(fset)
+
+	file, err := dec.ParseFile(name, src, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	if astFile, ok := dec.Ast.Nodes[file].(*ast.File); ok && astFile.Doc != nil && idx.packageDoc == "" {
+		idx.packageDoc = strings.TrimSpace(astFile.Doc.Text())
+	}
+
+	imports := make(map[string]string)
+	for _, spec := range file.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return err
+		}
+
+		local := assumedPackageName(importPath)
+		if spec.Name != nil {
+			local = spec.Name.Name
+		}
+		imports[local] = importPath
+	}
+	idx.imports[name] = imports
+
+	for _, decl := range file.Decls {
+		node, ok := dec.Ast.Nodes[decl]
+		if !ok {
+			continue
+		}
+
+		var comment *ast.CommentGroup
+		switch n := node.(type) {
+		case *ast.FuncDecl:
+			comment = n.Doc
+		case *ast.GenDecl:
+			if n.Tok == token.IMPORT {
+				continue
+			}
+			comment = n.Doc
+			// A single spec in a parenthesized block carries its own doc.
+			if comment == nil && len(n.Specs) == 1 {
+				if ts, ok := n.Specs[0].(*ast.TypeSpec); ok {
+					comment = ts.Doc
+				}
+			}
+		}
+
+		start := node.Pos()
+		if comment != nil {
+			start = min(start, comment.Pos())
+			idx.docs[decl] = strings.TrimSpace(comment.Text())
+		}
+
+		idx.file.Decls = append(idx.file.Decls, decl)
+		idx.locations[decl] = SourceLocation{
+			Name:      declName(decl),
+			File:      name,
+			StartLine: fset.Position(start).Line,
+			EndLine:   fset.Position(node.End()).Line,
+		}
+	}
+
+	return nil
+}
+
+// assumedPackageName returns the name an unaliased import of importPath is
+// referred to by, following the same conventions as goimports: the last path
+// element, without a major version element ("/v2"), a "go-" prefix or
+// anything from the first character that is not valid in an identifier
+// (".v3", "-go"). So "github.com/goccy/go-json" is "json" and
+// "gopkg.in/yaml.v3" is "yaml".
+func assumedPackageName(importPath string) string {
+	base := path.Base(importPath)
+	if strings.HasPrefix(base, "v") {
+		if _, err := strconv.Atoi(base[1:]); err == nil && path.Dir(importPath) != "." {
+			base = path.Base(path.Dir(importPath))
+		}
+	}
+	base = strings.TrimPrefix(base, "go-")
+	if i := strings.IndexFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	}); i >= 0 {
+		base = base[:i]
+	}
+	return base
+}
+
+// benchmarkSources returns the locations of all declarations that make up
+// the benchmark code of an implementation, in the same order as they appear
+// in Benchmark.BenchmarkCode.
+func (idx *sourceIndex) benchmarkSources(name string) []SourceLocation {
+	var sources []SourceLocation
+	for _, decl := range benchmarkDecls(idx.file, name) {
+		sources = append(sources, idx.locations[decl])
+	}
+
+	return sources
+}
+
+// importedSelector is a qualified identifier referring to an imported package.
+type importedSelector struct {
+	importPath string
+	name       string
+}
+
+// importedSelectors returns all qualified identifiers in decls that refer to
+// an imported package, resolved against the imports of each declaration's file.
+func (idx *sourceIndex) importedSelectors(decls []dst.Decl) []importedSelector {
+	var selectors []importedSelector
+	for _, decl := range decls {
+		imports := idx.imports[idx.locations[decl].File]
+		dst.Inspect(decl, func(n dst.Node) bool {
+			if sel, ok := n.(*dst.SelectorExpr); ok {
+				if pkg, ok := sel.X.(*dst.Ident); ok && imports[pkg.Name] != "" {
+					selectors = append(selectors, importedSelector{importPath: imports[pkg.Name], name: sel.Sel.Name})
+				}
+			}
+			return true
+		})
+	}
+
+	return selectors
+}
+
+// implementationDoc returns the doc comment describing an implementation.
+// The doc comment of the implementation type is preferred, falling back to
+// the first documented benchmark function of the implementation.
+func (idx *sourceIndex) implementationDoc(name string) string {
+	for _, decl := range idx.file.Decls {
+		d, ok := decl.(*dst.GenDecl)
+		if !ok || d.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range d.Specs {
+			if ts, ok := spec.(*dst.TypeSpec); ok && ts.Name.Name == name && idx.docs[decl] != "" {
+				return idx.docs[decl]
+			}
+		}
+	}
+
+	for _, decl := range idx.file.Decls {
+		if fd, ok := decl.(*dst.FuncDecl); ok && strings.HasPrefix(fd.Name.Name, "Benchmark"+name+"_") && idx.docs[decl] != "" {
+			return idx.docs[decl]
+		}
+	}
+
+	return ""
+}
+
+// packageSynopsis returns the first sentence of the package doc comment.
+func (idx *sourceIndex) packageSynopsis() string {
+	return new(doc.Package).Synopsis(idx.packageDoc)
+}
+
+// declName returns a human-readable name for a top-level declaration.
+// Methods are named like in stack traces: "(*T).method" or "T.method".
+func declName(decl dst.Decl) string {
+	switch d := decl.(type) {
+	case *dst.FuncDecl:
+		if d.Recv == nil || len(d.Recv.List) == 0 {
+			return d.Name.Name
+		}
+		if _, ok := d.Recv.List[0].Type.(*dst.StarExpr); ok {
+			return "(*" + recvTypeName(d) + ")." + d.Name.Name
+		}
+		return recvTypeName(d) + "." + d.Name.Name
+	case *dst.GenDecl:
+		var names []string
+		for _, spec := range d.Specs {
+			switch s := spec.(type) {
+			case *dst.TypeSpec:
+				names = append(names, s.Name.Name)
+			case *dst.ValueSpec:
+				for _, n := range s.Names {
+					names = append(names, n.Name)
+				}
+			}
+		}
+		return strings.Join(names, ", ")
+	}
+
+	return ""
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreFileName is the name of the file in the benchmarks directory that
+// lists paths to exclude from group discovery.
+const IgnoreFileName = ".gobenchignore"
+
+// IgnoreRules holds the patterns of a .gobenchignore file.
+//
+// Each non-empty line that does not start with "#" is a path.Match pattern.
+// Patterns containing a "/" (including a leading one) are matched against
+// the slash-separated path relative to the benchmarks directory; all other
+// patterns are matched against every path element. A trailing "/" is ignored.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	pattern  string
+	anchored bool // Match against the whole relative path instead of each element
+}
+
+// LoadIgnoreFile reads ignore rules from the named file. A missing file yields empty rules.
+func LoadIgnoreFile(name string) (IgnoreRules, error) {
+	f, err := os.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return IgnoreRules{}, nil
+	}
+	if err != nil {
+		return IgnoreRules{}, fmt.Errorf("failed to open ignore file: %w", err)
+	}
+	defer f.Close()
+
+	var rules IgnoreRules
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		pattern := strings.TrimSuffix(line, "/")
+		anchored := strings.Contains(pattern, "/")
+		pattern = strings.TrimPrefix(pattern, "/")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return IgnoreRules{}, fmt.Errorf("invalid ignore pattern %q: %w", line, err)
+		}
+		rules.patterns = append(rules.patterns, ignorePattern{pattern: pattern, anchored: anchored})
+	}
+
+	return rules, scanner.Err()
+}
+
+// Match reports whether the slash-separated relative path rel is ignored.
+func (r IgnoreRules) Match(rel string) bool {
+	for _, p := range r.patterns {
+		if p.anchored {
+			if ok, _ := path.Match(p.pattern, rel); ok {
+				return true
+			}
+			continue
+		}
+
+		for _, elem := range strings.Split(rel, "/") {
+			if ok, _ := path.Match(p.pattern, elem); ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
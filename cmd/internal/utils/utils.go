@@ -1,30 +1,104 @@
 package utils
 
 import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"unicode"
 )
 
+// InternalDir is the directory below the benchmarks directory that holds
+// helper packages shared between benchmark groups.
+const InternalDir = "internal"
+
+// WalkOverBenchmarks calls f for every benchmark group below basePath.
+// Directories that are not groups are treated as categories and searched
+// recursively; groups themselves are not descended into. The InternalDir,
+// directories named "testdata" or starting with "." or "_", and paths
+// matched by the .gobenchignore file in basePath, are skipped.
 func WalkOverBenchmarks(basePath string, f func(path string) error) error {
-	return filepath.Walk(basePath, func(path string, info os.FileInfo, err error) error {
+	ignore, err := LoadIgnoreFile(filepath.Join(basePath, IgnoreFileName))
+	if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(basePath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
 		// Skip the base benchmarks directory
-		if path == basePath {
+		if path == basePath || !d.IsDir() {
 			return nil
 		}
 
-		if info.IsDir() {
-			return f(path)
+		name := d.Name()
+		if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+			return filepath.SkipDir
+		}
+
+		rel, err := filepath.Rel(basePath, path)
+		if err != nil {
+			return err
+		}
+		if rel == InternalDir || ignore.Match(filepath.ToSlash(rel)) {
+			return filepath.SkipDir
+		}
+
+		isGroup, err := IsBenchmarkGroup(path)
+		if err != nil {
+			return err
+		}
+		if !isGroup {
+			return nil
 		}
 
-		return nil
+		if err := f(path); err != nil {
+			return err
+		}
+
+		return filepath.SkipDir
 	})
 }
 
+// IsBenchmarkGroup reports whether dir is a benchmark group, i.e. whether it
+// contains a _meta.yml file or a *_test.go file with at least one Benchmark
+// function.
+func IsBenchmarkGroup(dir string) (bool, error) {
+	if _, err := os.Stat(filepath.Join(dir, "_meta.yml")); err == nil {
+		return true, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+
+	fset := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(fset, filepath.Join(dir, entry.Name()), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return false, err
+		}
+
+		for _, decl := range file.Decls {
+			if fd, ok := decl.(*ast.FuncDecl); ok && fd.Recv == nil && strings.HasPrefix(fd.Name.Name, "Benchmark") {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
 func SplitCamelCase(src string) []string {
 	var result []string
 	var wordStart int
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWalkOverBenchmarks(t *testing.T) {
+	base := t.TempDir()
+
+	files := map[string]string{
+		"counter/counter_test.go":                  "package counter\n\nfunc BenchmarkA_run(b *testing.B) {}\n",
+		"counter/testdata/data_test.go":            "package testdata\n\nfunc BenchmarkB_run(b *testing.B) {}\n",
+		"concurrency/maps/_meta.yml":               "name: Maps\n",
+		"concurrency/maps/helper/helper.go":        "package helper\n",
+		"concurrency/mutex/mutex_test.go":          "package mutex\n\nfunc BenchmarkC_run(b *testing.B) {}\n",
+		"concurrency/helpers/helpers_test.go":      "package helpers\n\nfunc TestX(t *testing.T) {}\n",
+		"experimental/wip/wip_test.go":             "package wip\n\nfunc BenchmarkD_run(b *testing.B) {}\n",
+		"concurrency/experimental/exp/exp_test.go": "package exp\n\nfunc BenchmarkE_run(b *testing.B) {}\n",
+		"concurrency/broken/broken_test.go":        "package broken\n\nfunc BenchmarkF_run(b *testing.B) {}\n",
+		"internal/random/random_test.go":           "package random\n\nfunc BenchmarkG_run(b *testing.B) {}\n",
+		IgnoreFileName:                             "# comment\n/experimental\nconcurrency/broken/\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(base, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	err := WalkOverBenchmarks(base, func(path string) error {
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		got = append(got, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"concurrency/experimental/exp", "concurrency/maps", "concurrency/mutex", "counter"}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected groups %v, got %v", want, got)
+	}
+}
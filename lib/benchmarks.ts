@@ -14,7 +14,207 @@ export interface BenchmarkVariation {
   Ord: number;
   Name: string;
   CPUCount: number;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
   OpsPerSec: number;
+  Runs?: number;
+  MedianCI?: number;
+  NormalizedNsPerOp?: number;
+}
+
+export interface RunRecord {
+  Adaptive: boolean;
+  Runs: number;
+  TargetCI?: number;
+  StopReason?: "stable" | "max-count" | "max-time";
+  MedianCI?: number;
+  Order: "sequential" | "interleaved";
+  Seed?: number;
+  Seconds: number;
+  Conditions?: RunConditions[];
+  Noise?: NoiseRecord;
+  Calibration?: Calibration;
+  Sources?: Record<string, string>;
+}
+
+export interface RunConditions {
+  Run: number;
+  Temperature?: number;
+  Load?: number;
+  Cooldown?: number;
+  CooldownTimedOut?: boolean;
+  Sentinel?: number;
+  Deviation?: number;
+  Noisy?: boolean;
+  Discarded?: boolean;
+}
+
+export interface Calibration {
+  GoOS: string;
+  GoArch: string;
+  CPU?: string;
+  NumCPU: number;
+  GoVersion: string;
+  Date: string;
+  Score: number;
+  Results: { Name: string; NsPerOp: number }[];
+}
+
+export interface NoiseRecord {
+  Threshold: number;
+  Baseline: number;
+  Score: number;
+  SessionScore: number;
+  NoisyRuns?: number;
+  DiscardedRuns?: number;
+}
+
+export interface GoVersionChange {
+  Variation: string;
+  CPUCount: number;
+  Labels?: Record<string, string>;
+  From: string;
+  To: string;
+  NsPerOpFrom: number;
+  NsPerOpTo: number;
+  Change: number;
+}
+
+export interface VariantResult {
+  Variant: string;
+  Variation: string;
+  CPUCount: number;
+  GoVersion?: string;
+  Labels?: Record<string, string>;
+  Baseline?: string;
+  NsPerOpBaseline: number;
+  NsPerOp: number;
+  Change: number;
+  FastestBaseline: boolean;
+  Fastest: boolean;
+}
+
+export interface ProfileFunction {
+  Name: string;
+  File?: string;
+  Line?: number;
+  Flat: number;
+  Cum: number;
+}
+
+export interface Profile {
+  Kind: "cpu" | "mem" | "block" | "mutex";
+  Variation: string;
+  Labels?: Record<string, string>;
+  File: string;
+  FlameGraph?: string;
+  SampleType: string;
+  Unit: string;
+  Total: number;
+  Functions: ProfileFunction[];
+  AllocationSites?: ProfileFunction[];
+}
+
+export interface Instruction {
+  File: string;
+  Line: number;
+  Address: string;
+  Op: string;
+}
+
+export interface AssemblyFunction {
+  Symbol: string;
+  Name: string;
+  Instructions: Instruction[];
+}
+
+export interface CompilerNote {
+  Kind: "inlined" | "not inlined" | "escapes" | "bounds check";
+  Name: string;
+  File: string;
+  Line: number;
+  Column: number;
+  Message: string;
+}
+
+export interface CostFit {
+  Variation: string;
+  CPUCount: number;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
+  NsPerOp: number;
+  OverheadNs: number;
+  RSquared: number;
+  Points: number;
+  Linear: boolean;
+}
+
+export interface ScalingPoint {
+  CPUCount: number;
+  NsPerOp: number;
+  Speedup: number;
+  Efficiency: number;
+}
+
+export interface Scaling {
+  Variation: string;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
+  Points: ScalingPoint[];
+  SerialFraction: number;
+  ScaledSerialFraction: number;
+  NegativeScaling: boolean;
+}
+
+export interface ComplexityFit {
+  Variation: string;
+  CPUCount: number;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
+  Class: "O(1)" | "O(log n)" | "O(n)" | "O(n log n)" | "O(n²)";
+  Coefficient: number;
+  Constant: number;
+  RelativeError: number;
+  Sizes: number;
+}
+
+export interface Crossover {
+  Variation: string;
+  CPUCount: number;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
+  Size: number;
+  FasterBelow: string;
+  FasterAbove: string;
+}
+
+export interface Sweep {
+  Const: string;
+  Values: string[];
+}
+
+export interface Dependency {
+  Path: string;
+  Version: string;
+  Replace?: string;
+}
+
+export interface SourceLocation {
+  Name: string;
+  Package?: string;
+  File: string;
+  StartLine: number;
+  EndLine: number;
+}
+
+export interface SourceFile {
+  Name: string;
+  Code: string;
 }
 
 export interface Benchmark {
@@ -22,6 +222,16 @@ export interface Benchmark {
   Description: string;
   BenchmarkCode: string;
   Code: string;
+  Sources?: SourceLocation[];
+  Dependencies?: Dependency[];
+  GoVersionChanges?: GoVersionChange[];
+  Variants?: VariantResult[];
+  Profiles?: Profile[];
+  Assembly?: AssemblyFunction[];
+  CompilerNotes?: CompilerNote[];
+  CostFits?: CostFit[];
+  Scaling?: Scaling[];
+  Complexity?: ComplexityFit[];
   Variations: BenchmarkVariation[];
 }
 
@@ -33,6 +243,9 @@ export interface SystemInfo {
 }
 
 export interface BenchmarkGroup {
+  Path?: string;
+  Category?: string;
+  Module?: string;
   Name: string;
   Headline: string;
   Description: string;
@@ -40,6 +253,34 @@ export interface BenchmarkGroup {
   Benchmarks: Benchmark[];
   Code: string;
   Constants: string;
+  Files?: SourceFile[];
+  GoVersions?: string[];
+  Variants?: string[];
+  Crossovers?: Crossover[];
+  Sweeps?: Sweep[];
+  Run?: RunRecord;
+  Machine: string;
+  Machines?: MachineResults[];
+  Consistency?: WinnerConsistency[];
+}
+
+export interface MachineResults {
+  Machine: string;
+  System: SystemInfo;
+  Run?: RunRecord;
+  Benchmarks: { Name: string; Variations: BenchmarkVariation[] }[];
+}
+
+export interface WinnerConsistency {
+  Variation: string;
+  CPUCount: number;
+  GoVersion?: string;
+  Variant?: string;
+  Labels?: Record<string, string>;
+  Winners: Record<string, string>;
+  Winner: string;
+  Agreement: number;
+  Consistent: boolean;
 }
 
 // Matches _meta.yml structure
@@ -72,17 +313,47 @@ function getBenchmarksDir(): string {
   return path.join(process.cwd(), "benchmarks");
 }
 
-/** Returns all benchmark slugs (directory names that contain a _bench.json). */
+/**
+ * Returns all benchmark slugs: the slash-separated paths of the groups that
+ * have a _bench.json, relative to the benchmarks directory. Groups may be
+ * organised in category directories (e.g. "concurrency/counter"); like
+ * WalkOverBenchmarks in cmd/internal/utils, directories starting with "." or
+ * "_", testdata, the shared helpers in internal and subdirectories of groups
+ * are skipped.
+ */
 export function getAllSlugs(): string[] {
   const dir = getBenchmarksDir();
-  return fs
-    .readdirSync(dir, { withFileTypes: true })
-    .filter(
-      (entry) =>
-        entry.isDirectory() &&
-        fs.existsSync(path.join(dir, entry.name, "_bench.json")),
-    )
-    .map((entry) => entry.name);
+  const slugs: string[] = [];
+
+  const walk = (rel: string) => {
+    for (const entry of fs.readdirSync(path.join(dir, rel), {
+      withFileTypes: true,
+    })) {
+      const name = entry.name;
+      if (
+        !entry.isDirectory() ||
+        name === "testdata" ||
+        name.startsWith(".") ||
+        name.startsWith("_")
+      ) {
+        continue;
+      }
+
+      const slug = rel ? `${rel}/${name}` : name;
+      if (slug === "internal") {
+        continue;
+      }
+
+      if (fs.existsSync(path.join(dir, slug, "_bench.json"))) {
+        slugs.push(slug);
+      } else if (!fs.existsSync(path.join(dir, slug, "_meta.yml"))) {
+        walk(slug);
+      }
+    }
+  };
+  walk("");
+
+  return slugs.sort();
 }
 
 /** Parses the _bench.json for a given benchmark slug. */